@@ -0,0 +1,84 @@
+// Command argon2-tune benchmarks argon2id on the current machine and prints
+// the strongest parameters that hash a password within a target duration,
+// ready to paste into .env. Run it on the hardware that serves logins.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/password"
+)
+
+// OWASP's floor for argon2id is 19 MiB of memory with 2 iterations
+const minMemoryMiB = 19
+
+func main() {
+	target := flag.Duration("target", 500*time.Millisecond, "longest acceptable time to hash one password")
+	maxMemory := flag.Uint("max-memory", 256, "most memory to use per hash, in MiB")
+	parallelism := flag.Uint("parallelism", uint(min(runtime.NumCPU(), 4)), "threads per hash")
+	samples := flag.Int("samples", 3, "hashes timed for each candidate")
+	flag.Parse()
+
+	if *maxMemory < minMemoryMiB || *parallelism == 0 || *parallelism > 255 || *samples < 1 {
+		log.Fatalf("max-memory must be at least %d MiB, parallelism 1-255 and samples at least 1", minMemoryMiB)
+	}
+
+	params := password.DefaultArgon2Params
+	params.Parallelism = uint8(*parallelism)
+	params.Iterations = 1
+
+	// Memory is the main defence against GPU cracking, so find the largest
+	// amount that fits the target with a single pass first
+	memoryMiB := *maxMemory
+	for {
+		params.Memory = uint32(memoryMiB * 1024)
+		elapsed := measure(params, *samples)
+		fmt.Printf("m=%d MiB, t=%d: %v\n", memoryMiB, params.Iterations, elapsed)
+		if elapsed <= *target {
+			break
+		}
+		if memoryMiB/2 < minMemoryMiB {
+			log.Fatalf("even %d MiB takes longer than %v; raise the target or use faster hardware", memoryMiB, *target)
+		}
+		memoryMiB /= 2
+	}
+
+	// Then spend the rest of the budget on extra passes
+	for {
+		next := params
+		next.Iterations++
+		elapsed := measure(next, *samples)
+		fmt.Printf("m=%d MiB, t=%d: %v\n", memoryMiB, next.Iterations, elapsed)
+		if elapsed > *target {
+			break
+		}
+		params = next
+	}
+
+	if memoryMiB < 46 && params.Iterations < 2 {
+		fmt.Println("\nWarning: these parameters are below the OWASP recommendation (19 MiB with t=2, or 46 MiB with t=1)")
+	}
+
+	fmt.Printf("\nPASSWORD_HASH_ALGORITHM=%s\nARGON2_MEMORY=%d\nARGON2_ITERATIONS=%d\nARGON2_PARALLELISM=%d\n",
+		password.AlgorithmArgon2id, params.Memory, params.Iterations, params.Parallelism)
+}
+
+// measure returns the median time to hash a password with the given parameters
+func measure(params password.Argon2Params, samples int) time.Duration {
+	salt := make([]byte, params.SaltLength)
+	times := make([]time.Duration, samples)
+	for i := range times {
+		start := time.Now()
+		argon2.IDKey([]byte("benchmark password"), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		times[i] = time.Since(start)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
@@ -139,6 +139,40 @@ var (
 	dbInstance *service
 )
 
+// Models lists every table AutoMigrate manages
+func Models() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.Post{},
+		&models.Comment{},
+		&models.Follow{},
+		&models.Vote{},
+		&models.UserToken{},
+		&models.Session{},
+		&models.SecurityEvent{},
+		&models.ReservedUsername{},
+		&models.RecoveryCode{},
+		&models.PhoneCode{},
+		&models.LoginAttempt{},
+		&models.UserIdentity{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnSession{},
+		&models.DataExport{},
+		&models.Block{},
+		&models.UserPreferences{},
+		&models.PostMedia{},
+		&models.Media{},
+		&models.MediaVariant{},
+		&models.LinkPreview{},
+		&models.Revision{},
+		&models.SavedItem{},
+		&models.SavedCollection{},
+		&models.HiddenPost{},
+		&models.PostView{},
+		&models.Community{},
+	}
+}
+
 func New() Service {
 	// Reuse Connection
 	if dbInstance != nil {
@@ -177,17 +211,39 @@ func New() Service {
 	log.Println("✅ Database connected successfully")
 
 	// Auto migrate schemas
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Post{},
-		&models.Comment{},
-		&models.Follow{},
-		&models.Vote{},
-	)
+	err = db.AutoMigrate(Models()...)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Copy provider IDs stored on users into user_identities
+	err = db.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		SELECT id, 'google', google_id, email, NOW() FROM users WHERE google_id <> ''
+		UNION ALL
+		SELECT id, 'apple', apple_id, email, NOW() FROM users WHERE apple_id <> ''
+		ON CONFLICT DO NOTHING`).Error
+	if err != nil {
+		log.Fatalf("Failed to backfill user identities: %v", err)
+	}
+
+	// Turn posts created before post kinds existed with an image into image posts
+	err = db.Exec(`
+		INSERT INTO post_media (post_id, position, url, created_at)
+		SELECT id, 0, image, created_at FROM posts
+		WHERE image <> '' AND kind = 'text' AND NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.post_id = posts.id)`).Error
+	if err == nil {
+		err = db.Exec(`UPDATE posts SET kind = 'image' WHERE image <> '' AND kind = 'text'`).Error
+	}
+	if err != nil {
+		log.Fatalf("Failed to backfill post media: %v", err)
+	}
+
+	// Videos aren't processed; images uploaded earlier are picked up by the media job
+	if err := db.Exec(`UPDATE media SET status = 'ready' WHERE kind = 'video' AND status = 'pending'`).Error; err != nil {
+		log.Fatalf("Failed to backfill media status: %v", err)
+	}
+
 	log.Println("✅ Database migrations completed")
 
 	// Configure connection pool
@@ -0,0 +1,139 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Table is one dataset of the export, written as both <Name>.json and <Name>.csv
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Add appends a row; values must line up with Columns
+func (t *Table) Add(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// Archive is everything held about one user
+type Archive struct {
+	UserID      int
+	GeneratedAt time.Time
+	Tables      []*Table
+}
+
+const readme = `This archive contains the personal data held about your account.
+
+Every dataset is included twice: as JSON (one object per record) and as CSV
+(one row per record, with a header row). Times are in UTC, RFC 3339 format.
+`
+
+// WriteZip writes the archive as a zip file
+func (a *Archive) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeFile(zw, "README.txt", a.GeneratedAt, func(f io.Writer) error {
+		_, err := fmt.Fprintf(f, "%s\nUser ID: %d\nGenerated: %s\n", readme, a.UserID, a.GeneratedAt.UTC().Format(time.RFC3339))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	for _, table := range a.Tables {
+		if err := writeFile(zw, table.Name+".json", a.GeneratedAt, table.writeJSON); err != nil {
+			return err
+		}
+		if err := writeFile(zw, table.Name+".csv", a.GeneratedAt, table.writeCSV); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeFile(zw *zip.Writer, name string, modified time.Time, write func(io.Writer) error) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (t *Table) writeJSON(w io.Writer) error {
+	records := make([]map[string]interface{}, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		record := make(map[string]interface{}, len(t.Columns))
+		for i, column := range t.Columns {
+			record[column] = jsonValue(row[i])
+		}
+		records = append(records, record)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func (t *Table) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = csvValue(value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC().Format(time.RFC3339)
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	default:
+		return v
+	}
+}
+
+func csvValue(v interface{}) string {
+	switch v := jsonValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,105 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestWriteZip(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	parent := 4
+
+	comments := &Table{Name: "comments", Columns: []string{"id", "parent_comment_id", "body", "created_at", "edited_at"}}
+	comments.Add(1, nil, "hello, \"world\"", created, (*time.Time)(nil))
+	comments.Add(2, &parent, "reply", created, &created)
+
+	archive := &Archive{UserID: 7, GeneratedAt: created, Tables: []*Table{comments}}
+
+	var buf bytes.Buffer
+	if err := archive.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip() error: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+
+	for _, name := range []string{"README.txt", "comments.json", "comments.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("archive is missing %s", name)
+		}
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(files["comments.json"], &records); err != nil {
+		t.Fatalf("comments.json is not valid JSON: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 JSON records, got %d", len(records))
+	}
+	if records[0]["parent_comment_id"] != nil || records[0]["edited_at"] != nil {
+		t.Errorf("nil values should be null in JSON, got %v", records[0])
+	}
+	if records[1]["parent_comment_id"] != float64(4) || records[1]["created_at"] != "2026-03-01T12:30:00Z" {
+		t.Errorf("unexpected second record %v", records[1])
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(files["comments.csv"])).ReadAll()
+	if err != nil {
+		t.Fatalf("comments.csv is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		{"id", "parent_comment_id", "body", "created_at", "edited_at"},
+		{"1", "", "hello, \"world\"", "2026-03-01T12:30:00Z", ""},
+		{"2", "4", "reply", "2026-03-01T12:30:00Z", "2026-03-01T12:30:00Z"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d CSV rows, got %d", len(want), len(rows))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("row %d column %d = %q, want %q", i, j, rows[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestWriteZipEmptyTable(t *testing.T) {
+	archive := &Archive{UserID: 1, GeneratedAt: time.Now(), Tables: []*Table{{Name: "votes", Columns: []string{"post_id"}}}}
+
+	var buf bytes.Buffer
+	if err := archive.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip() error: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+
+	if got := string(bytes.TrimSpace(files["votes.json"])); got != "[]" {
+		t.Errorf("empty table should be an empty JSON array, got %q", got)
+	}
+	if got := string(bytes.TrimSpace(files["votes.csv"])); got != "post_id" {
+		t.Errorf("empty table should only have a CSV header, got %q", got)
+	}
+}
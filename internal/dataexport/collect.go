@@ -0,0 +1,212 @@
+package dataexport
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// Collect gathers everything stored about a user. Secrets such as password
+// hashes, 2FA secrets and passkey public keys are left out.
+func Collect(ctx context.Context, db *gorm.DB, userID int) (*Archive, error) {
+	db = db.WithContext(ctx)
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{UserID: user.ID, GeneratedAt: time.Now()}
+
+	profile := &Table{Name: "profile", Columns: []string{
+		"id", "username", "email", "email_verified", "bio", "avatar", "phone", "phone_verified",
+		"auth_provider", "totp_enabled", "sms_mfa_enabled", "created_at", "updated_at", "deletion_scheduled_for",
+	}}
+	profile.Add(user.ID, user.Username, user.Email, user.EmailVerified, user.Bio, user.Avatar, user.Phone, user.PhoneVerified,
+		user.AuthProvider, user.TOTPEnabled, user.SMSMFAEnabled, user.CreatedAt, user.UpdatedAt, user.DeletionScheduledFor)
+	archive.Tables = append(archive.Tables, profile)
+
+	prefs := models.DefaultUserPreferences(user.ID)
+	if err := db.Where("user_id = ?", user.ID).Limit(1).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	prefTable := &Table{Name: "preferences", Columns: []string{
+		"feed_sort", "comment_sort", "show_nsfw", "blur_spoilers", "email_post_replies", "email_comment_replies",
+		"email_mentions", "email_new_followers", "private_profile", "hide_followers", "hide_following", "hide_votes",
+		"hide_from_search", "pause_history", "language", "timezone",
+	}}
+	prefTable.Add(prefs.FeedSort, prefs.CommentSort, prefs.ShowNSFW, prefs.BlurSpoilers, prefs.EmailNotifications.PostReplies,
+		prefs.EmailNotifications.CommentReplies, prefs.EmailNotifications.Mentions, prefs.EmailNotifications.NewFollowers,
+		prefs.PrivateProfile, prefs.HideFollowers, prefs.HideFollowing, prefs.HideVotes, prefs.HideFromSearch,
+		prefs.PauseHistory, prefs.Language, prefs.Timezone)
+	archive.Tables = append(archive.Tables, prefTable)
+
+	var posts []models.Post
+	if err := db.Preload("Media", func(db *gorm.DB) *gorm.DB { return db.Order("position asc") }).
+		Where("user_id = ? OR author_id = ?", user.ID, user.ID).Order("created_at asc").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	postTable := &Table{Name: "posts", Columns: []string{"id", "kind", "title", "body", "content", "url", "media", "community", "nsfw", "spoiler", "created_at", "updated_at"}}
+	for _, p := range posts {
+		media := make([]string, 0, len(p.Media))
+		for _, m := range p.Media {
+			media = append(media, m.URL)
+		}
+		postTable.Add(p.ID, p.Kind, p.Title, p.Body, p.Content, p.URL, strings.Join(media, " "), p.Community, p.NSFW, p.Spoiler, p.CreatedAt, p.UpdatedAt)
+	}
+	archive.Tables = append(archive.Tables, postTable)
+
+	var comments []models.Comment
+	if err := db.Where("author_id = ?", user.ID).Order("created_at asc").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	commentTable := &Table{Name: "comments", Columns: []string{"id", "post_id", "parent_comment_id", "body", "created_at", "updated_at"}}
+	for _, cm := range comments {
+		commentTable.Add(cm.ID, cm.PostID, cm.ParentCommentID, cm.Body, cm.CreatedAt, cm.UpdatedAt)
+	}
+	archive.Tables = append(archive.Tables, commentTable)
+
+	var revisions []models.Revision
+	if err := db.Where("editor_id = ?", user.ID).Order("created_at asc").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	revisionTable := &Table{Name: "revisions", Columns: []string{"post_id", "comment_id", "title", "body", "created_at"}}
+	for _, r := range revisions {
+		revisionTable.Add(r.PostID, r.CommentID, r.Title, r.Body, r.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, revisionTable)
+
+	var uploads []models.Media
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	uploadTable := &Table{Name: "uploads", Columns: []string{"id", "kind", "storage_key", "content_type", "size", "width", "height", "created_at"}}
+	for _, m := range uploads {
+		uploadTable.Add(m.ID, m.Kind, m.Key, m.ContentType, m.Size, m.Width, m.Height, m.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, uploadTable)
+
+	var votes []models.Vote
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&votes).Error; err != nil {
+		return nil, err
+	}
+	voteTable := &Table{Name: "votes", Columns: []string{"post_id", "comment_id", "vote", "created_at", "updated_at"}}
+	for _, v := range votes {
+		voteTable.Add(v.PostID, v.CommentID, v.VoteType, v.CreatedAt, v.UpdatedAt)
+	}
+	archive.Tables = append(archive.Tables, voteTable)
+
+	var follows []models.Follow
+	if err := db.Preload("Follower").Preload("Following").
+		Where("follower_id = ? OR following_id = ?", user.ID, user.ID).
+		Order("created_at asc").Find(&follows).Error; err != nil {
+		return nil, err
+	}
+	followTable := &Table{Name: "follows", Columns: []string{"direction", "user_id", "username", "created_at"}}
+	for _, f := range follows {
+		if f.FollowerID == user.ID {
+			followTable.Add("following", f.FollowingID, f.Following.Username, f.CreatedAt)
+		} else {
+			followTable.Add("follower", f.FollowerID, f.Follower.Username, f.CreatedAt)
+		}
+	}
+	archive.Tables = append(archive.Tables, followTable)
+
+	var blocks []models.Block
+	if err := db.Preload("Blocked").Where("blocker_id = ?", user.ID).Order("created_at asc").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	blockTable := &Table{Name: "blocks", Columns: []string{"user_id", "username", "created_at"}}
+	for _, b := range blocks {
+		blockTable.Add(b.BlockedID, b.Blocked.Username, b.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, blockTable)
+
+	var collections []models.SavedCollection
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	collectionNames := map[int]string{}
+	for _, col := range collections {
+		collectionNames[col.ID] = col.Name
+	}
+	var saved []models.SavedItem
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	savedTable := &Table{Name: "saved", Columns: []string{"post_id", "comment_id", "collection", "created_at"}}
+	for _, s := range saved {
+		collection := ""
+		if s.CollectionID != nil {
+			collection = collectionNames[*s.CollectionID]
+		}
+		savedTable.Add(s.PostID, s.CommentID, collection, s.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, savedTable)
+
+	var hidden []models.HiddenPost
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&hidden).Error; err != nil {
+		return nil, err
+	}
+	hiddenTable := &Table{Name: "hidden_posts", Columns: []string{"post_id", "created_at"}}
+	for _, hp := range hidden {
+		hiddenTable.Add(hp.PostID, hp.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, hiddenTable)
+
+	var views []models.PostView
+	if err := db.Where("user_id = ?", user.ID).Order("viewed_at asc").Find(&views).Error; err != nil {
+		return nil, err
+	}
+	historyTable := &Table{Name: "history", Columns: []string{"post_id", "viewed_at"}}
+	for _, v := range views {
+		historyTable.Add(v.PostID, v.ViewedAt)
+	}
+	archive.Tables = append(archive.Tables, historyTable)
+
+	var sessions []models.Session
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	sessionTable := &Table{Name: "sessions", Columns: []string{"ip_address", "user_agent", "created_at", "expires_at", "revoked_at"}}
+	for _, s := range sessions {
+		sessionTable.Add(s.IPAddress, s.UserAgent, s.CreatedAt, s.ExpiresAt, s.RevokedAt)
+	}
+	archive.Tables = append(archive.Tables, sessionTable)
+
+	var events []models.SecurityEvent
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	eventTable := &Table{Name: "security_events", Columns: []string{"event", "details", "ip_address", "user_agent", "created_at"}}
+	for _, e := range events {
+		eventTable.Add(e.Event, e.Details, e.IPAddress, e.UserAgent, e.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, eventTable)
+
+	var identities []models.UserIdentity
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	identityTable := &Table{Name: "linked_accounts", Columns: []string{"provider", "email", "created_at"}}
+	for _, i := range identities {
+		identityTable.Add(i.Provider, i.Email, i.CreatedAt)
+	}
+	archive.Tables = append(archive.Tables, identityTable)
+
+	var passkeys []models.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&passkeys).Error; err != nil {
+		return nil, err
+	}
+	passkeyTable := &Table{Name: "passkeys", Columns: []string{"name", "created_at", "last_used_at"}}
+	for _, p := range passkeys {
+		passkeyTable.Add(p.Name, p.CreatedAt, p.LastUsedAt)
+	}
+	archive.Tables = append(archive.Tables, passkeyTable)
+
+	return archive, nil
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const (
+	// How often a user may rename their account
+	usernameChangeCooldown = 30 * 24 * time.Hour
+	// How long an old username stays reserved for its previous owner
+	usernameReservationPeriod = 30 * 24 * time.Hour
+
+	// Accounts without a password re-authenticate by having logged in this recently
+	recentLoginWindow = 10 * time.Minute
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,30}$`)
+
+type AccountHandler struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+}
+
+func NewAccountHandler(db *gorm.DB, m mailer.Mailer) *AccountHandler {
+	return &AccountHandler{db: db, mailer: m}
+}
+
+// currentUser loads the authenticated user, writing an error response on failure
+func (h *AccountHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// reauthenticate confirms it's really the user before a sensitive change:
+// the password (or a fresh login for accounts without one) and, with 2FA
+// on, a second factor. It writes an error response on failure.
+func (h *AccountHandler) reauthenticate(c *gin.Context, user *models.User, password string, codes mfaCodes) bool {
+	if user.Password != "" {
+		if !verifyPassword(h.db, user, password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return false
+		}
+	} else {
+		var session models.Session
+		err := h.db.Where("token_id = ?", c.GetString("session_id")).First(&session).Error
+		if err != nil || time.Since(session.CreatedAt) > recentLoginWindow {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Please log in again to confirm it's you",
+				"code":  "reauthentication_required",
+			})
+			return false
+		}
+	}
+
+	if requiresMFA(*user) && !verifySecondFactor(h.db, c, user, codes) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return false
+	}
+	return true
+}
+
+// ChangePassword sets a new password after checking the current one and
+// signs out every other session
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This account doesn't have a password. Use \"Forgot password\" to set one."})
+		return
+	}
+
+	if !verifyPassword(h.db, user, input.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, input.NewPassword, user.Username, user.Email) {
+		return
+	}
+
+	hashedPassword, err := hashPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user.Password = hashedPassword
+	if err := h.db.Save(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// Keep the current session, sign out everywhere else
+	if err := revokeUserSessions(h.db, user.ID, c.GetString("session_id")); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", user.ID, err)
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPasswordChanged, "")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password for your account was just changed and other devices have been signed out.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ChangeEmail starts an email change after re-authenticating the user like
+// DeleteAccount does. The new address only replaces the old one once it has
+// been verified through the emailed link.
+func (h *AccountHandler) ChangeEmail(c *gin.Context) {
+	var input struct {
+		Email           string `json:"email" binding:"required,email"`
+		CurrentPassword string `json:"current_password"`
+		mfaCodes
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// A stolen session alone mustn't be enough to take over the account
+	if !h.reauthenticate(c, user, input.CurrentPassword, input.mfaCodes) {
+		return
+	}
+
+	if input.Email == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "That is already your email address"})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.User{}).Where("email = ?", input.Email).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already in use"})
+		return
+	}
+
+	token, err := createUserToken(h.db, user.ID, models.TokenPurposeEmailChange, input.Email, emailVerificationTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start email change"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventEmailChangeRequested, input.Email)
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", appURL(), token)
+	sendEmail(h.mailer, mailer.Message{
+		To:      input.Email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to use this address for your account:\n\n%s\n\nThe link expires in 24 hours.\n",
+			user.Username, link,
+		),
+	})
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Email change requested",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA request was made to change your account email to %s. Nothing changes until the new address is confirmed.\n\nIf this wasn't you, change your password immediately.\n",
+			user.Username, input.Email,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Check your new inbox to confirm the email change"})
+}
+
+// ChangeUsername renames the account. Renames are limited by a cooldown and
+// the old name stays reserved for the user for a while.
+func (h *AccountHandler) ChangeUsername(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !usernamePattern.MatchString(input.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-30 characters of letters, numbers, '_' or '-'"})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if input.Username == user.Username {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "That is already your username"})
+		return
+	}
+
+	if user.UsernameChangedAt != nil {
+		if wait := usernameChangeCooldown - time.Since(*user.UsernameChangedAt); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":          "You can only change your username once every 30 days",
+				"next_change_at": user.UsernameChangedAt.Add(usernameChangeCooldown),
+			})
+			return
+		}
+	}
+
+	if usernameTaken(h.db, input.Username, user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is not available"})
+		return
+	}
+
+	oldUsername := user.Username
+	now := time.Now()
+	reservedUntil := now.Add(usernameReservationPeriod)
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Reclaiming a name this user reserved earlier releases the reservation;
+		// expired reservations of either name are cleaned up as well
+		if err := tx.Where("username IN ? AND (user_id = ? OR expires_at <= ?)", []string{input.Username, oldUsername}, user.ID, now).
+			Delete(&models.ReservedUsername{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.ReservedUsername{
+			Username:  oldUsername,
+			UserID:    user.ID,
+			ExpiresAt: &reservedUntil,
+		}).Error; err != nil {
+			return err
+		}
+
+		user.Username = input.Username
+		user.UsernameChangedAt = &now
+		return tx.Save(user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change username"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventUsernameChanged, fmt.Sprintf("%s -> %s", oldUsername, user.Username))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Username changed successfully",
+		"username":       user.Username,
+		"reserved_until": reservedUntil,
+	})
+}
+
+// GetSecurityEvents returns the current user's recent account security history
+func (h *AccountHandler) GetSecurityEvents(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var events []models.SecurityEvent
+	if err := h.db.Where("user_id = ?", userID).Order("created_at desc").Limit(100).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	if events == nil {
+		events = []models.SecurityEvent{}
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// usernameTaken reports whether a username belongs to another user or is
+// reserved for someone other than exceptUserID (pass 0 for new accounts)
+func usernameTaken(db *gorm.DB, username string, exceptUserID int) bool {
+	var count int64
+	db.Model(&models.User{}).Where("username = ? AND id <> ?", username, exceptUserID).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	db.Model(&models.ReservedUsername{}).
+		Where("username = ? AND user_id <> ? AND (expires_at IS NULL OR expires_at > ?)", username, exceptUserID, time.Now()).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	appleIssuer = "https://appleid.apple.com"
+
+	// Apple rotates its keys rarely; an unknown key ID triggers a refetch
+	// anyway, but no more often than appleKeysMinRefresh
+	appleKeysTTL        = 24 * time.Hour
+	appleKeysMinRefresh = time.Minute
+)
+
+// AppleUserInfo represents user data from Apple Sign In
+type AppleUserInfo struct {
+	Sub            string `json:"sub"`
+	Email          string `json:"email"`
+	EmailVerified  string `json:"email_verified"`
+	IsPrivateEmail string `json:"is_private_email"`
+}
+
+// appleKeySet caches the RSA keys Apple signs ID tokens with, by key ID
+type appleKeySet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+var appleKeys = &appleKeySet{
+	url:    "https://appleid.apple.com/auth/keys",
+	client: &http.Client{Timeout: 10 * time.Second},
+}
+
+// appleClientIDs returns the accepted token audiences from APPLE_CLIENT_ID,
+// a comma-separated list of the app's bundle and services IDs
+func appleClientIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv("APPLE_CLIENT_ID"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// key returns Apple's public key with the given ID, fetching the key set
+// when it is stale or doesn't have the key yet
+func (s *appleKeySet) key(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetched) > appleKeysTTL
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && time.Since(s.fetched) < appleKeysMinRefresh {
+		return nil, fmt.Errorf("unknown apple key %q", kid)
+	}
+
+	keys, err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.fetched = keys, time.Now()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown apple key %q", kid)
+	}
+	return key, nil
+}
+
+// fetch downloads and decodes Apple's JSON Web Key Set
+func (s *appleKeySet) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch apple keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch apple keys: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode apple keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid apple key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid apple key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// verifyAppleIDToken checks an Apple ID token's signature against Apple's
+// published keys, along with its issuer, audience and expiry
+func verifyAppleIDToken(idToken string) (*AppleUserInfo, error) {
+	return parseAppleIDToken(idToken, appleKeys, appleClientIDs())
+}
+
+func parseAppleIDToken(idToken string, keys *appleKeySet, audiences []string) (*AppleUserInfo, error) {
+	if len(audiences) == 0 {
+		return nil, errors.New("apple sign in is not configured")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(appleIssuer),
+		jwt.WithAudience(audiences...),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apple token: %w", err)
+	}
+
+	user := &AppleUserInfo{}
+	user.Sub, _ = claims["sub"].(string)
+	user.Email, _ = claims["email"].(string)
+	if user.Sub == "" || user.Email == "" {
+		return nil, errors.New("apple token is missing the subject or email")
+	}
+
+	// Apple sends these flags as either booleans or strings
+	user.EmailVerified = fmt.Sprint(claims["email_verified"])
+	if v, ok := claims["is_private_email"]; ok {
+		user.IsPrivateEmail = fmt.Sprint(v)
+	}
+	if user.EmailVerified != "true" {
+		return nil, errors.New("email not verified")
+	}
+
+	return user, nil
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseAppleIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+	keys := &appleKeySet{url: srv.URL, client: srv.Client()}
+
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":            appleIssuer,
+			"aud":            "com.example.app",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"sub":            "001234.abcd",
+			"email":          "user@privaterelay.appleid.com",
+			"email_verified": "true",
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	signed := func(c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+		token.Header["kid"] = "test-key"
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	user, err := parseAppleIDToken(signed(claims(nil)), keys, []string{"com.example.app"})
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if user.Sub != "001234.abcd" || user.Email != "user@privaterelay.appleid.com" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString([]byte("dummy-key-for-parsing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(nil))
+	wrongKey.Header["kid"] = "test-key"
+	wrongKeySigned, err := wrongKey.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := map[string]string{
+		"HMAC signed":      forged,
+		"wrong key":        wrongKeySigned,
+		"wrong issuer":     signed(claims(func(c jwt.MapClaims) { c["iss"] = "https://example.com" })),
+		"wrong audience":   signed(claims(func(c jwt.MapClaims) { c["aud"] = "com.example.other" })),
+		"expired":          signed(claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+		"no expiry":        signed(claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+		"no subject":       signed(claims(func(c jwt.MapClaims) { delete(c, "sub") })),
+		"no email":         signed(claims(func(c jwt.MapClaims) { delete(c, "email") })),
+		"email unverified": signed(claims(func(c jwt.MapClaims) { c["email_verified"] = false })),
+	}
+	for name, token := range rejected {
+		if _, err := parseAppleIDToken(token, keys, []string{"com.example.app"}); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	if _, err := parseAppleIDToken(signed(claims(nil)), keys, nil); err == nil {
+		t.Error("token accepted without a configured client ID")
+	}
+}
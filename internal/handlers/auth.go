@@ -3,25 +3,29 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/emilythestrangee/reddit-clone/backend/internal/loginguard"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
 	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/passkey"
 )
 
 type AuthHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	mailer   mailer.Mailer
+	guard    *loginguard.Guard
+	passkeys *passkey.Service
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(db *gorm.DB, m mailer.Mailer, guard *loginguard.Guard, passkeys *passkey.Service) *AuthHandler {
+	return &AuthHandler{db: db, mailer: m, guard: guard, passkeys: passkeys}
 }
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
@@ -37,14 +41,6 @@ type GoogleUserInfo struct {
 	FamilyName    string `json:"family_name"`
 }
 
-// AppleUserInfo represents user data from Apple Sign In
-type AppleUserInfo struct {
-	Sub            string `json:"sub"`
-	Email          string `json:"email"`
-	EmailVerified  string `json:"email_verified"`
-	IsPrivateEmail string `json:"is_private_email"`
-}
-
 // verifyGoogleIDToken verifies the Google ID token and returns user info
 func verifyGoogleIDToken(idToken string) (*GoogleUserInfo, error) {
 	resp, err := http.Get(
@@ -71,50 +67,12 @@ func verifyGoogleIDToken(idToken string) (*GoogleUserInfo, error) {
 	return &user, nil
 }
 
-// verifyAppleIDToken verifies Apple ID token (simplified version)
-// In production, you should use Apple's public keys to verify JWT signature
-func verifyAppleIDToken(idToken string) (*AppleUserInfo, error) {
-	// Split the JWT token
-	parts := strings.Split(idToken, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid token format")
-	}
-
-	// Decode the payload (base64)
-	// Note: In production, you MUST verify the signature using Apple's public keys
-	// This is a simplified version for demonstration
-
-	// For now, we'll just parse the token claims
-	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
-		// In production, fetch and use Apple's public keys
-		return []byte("dummy-key-for-parsing"), nil
-	})
-
-	if err != nil {
-		// If parsing fails, return error
-		// In production, you should properly verify with Apple's keys
-		return nil, fmt.Errorf("failed to parse token: %w", err)
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("invalid token claims")
-	}
-
-	user := &AppleUserInfo{
-		Sub:   claims["sub"].(string),
-		Email: claims["email"].(string),
-	}
-
-	return user, nil
-}
-
 // Register handles user registration
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input struct {
 		Username string `json:"username" binding:"required"`
 		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
 		Avatar   string `json:"avatar"`
 	}
 
@@ -123,14 +81,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if username or email already exists
+	// Check if username or email already exists (including reserved usernames)
 	var existingUser models.User
-	if err := h.db.Where("username = ? OR email = ?", input.Username, input.Email).First(&existingUser).Error; err == nil {
+	if err := h.db.Where("username = ? OR email = ?", input.Username, input.Email).First(&existingUser).Error; err == nil || usernameTaken(h.db, input.Username, 0) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if !checkPasswordPolicy(c, input.Password, input.Username, input.Email) {
+		return
+	}
+
+	hashedPassword, err := hashPassword(input.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
@@ -139,7 +101,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	user := models.User{
 		Username:     input.Username,
 		Email:        input.Email,
-		Password:     string(hashedPassword),
+		Password:     hashedPassword,
 		Avatar:       input.Avatar,
 		AuthProvider: "email",
 	}
@@ -149,15 +111,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token AFTER creating user
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
-	})
+	// Email a verification link; the account is usable while unverified
+	if err := h.sendVerificationEmail(user, user.Email); err != nil {
+		log.Printf("Failed to create verification token for user %d: %v", user.ID, err)
+	}
 
-	tokenString, err := token.SignedString(jwtSecret)
+	// Generate JWT token AFTER creating user
+	tokenString, err := issueSession(h.db, c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -167,10 +127,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		"message": "User registered successfully",
 		"token":   tokenString, // ✅ ADD TOKEN
 		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"avatar":   user.Avatar,
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"avatar":         user.Avatar,
+			"email_verified": user.EmailVerified,
 		},
 	})
 }
@@ -187,44 +148,91 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Throttle brute-force attempts per account and per IP
+	if !h.loginAllowed(c, input.Email) {
+		return
+	}
+
 	var user models.User
-	if err := h.db.Where("email = ? AND auth_provider = ?", input.Email, "email").First(&user).Error; err != nil {
+	if err := h.db.Where("email = ? AND password <> ''", input.Email).First(&user).Error; err != nil {
+		h.loginFailed(c, input.Email, nil)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+	if !verifyPassword(h.db, &user, input.Password) {
+		h.loginFailed(c, input.Email, &user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(), // 72 hours
-	})
+	h.completeLogin(c, user)
+}
+
+// completeLogin finishes a successful first-factor login: accounts with 2FA
+// get a short-lived challenge token, everyone else gets a session
+func (h *AuthHandler) completeLogin(c *gin.Context, user models.User) {
+	if requiresMFA(user) {
+		mfaToken, err := issueMFAToken(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"methods":      mfaMethods(h.db, user),
+		})
+		return
+	}
+
+	h.startLoginSession(c, user)
+}
 
-	tokenString, err := token.SignedString(jwtSecret)
+// startLoginSession signs the user in once every login step has passed.
+// Logging in during the deletion grace period cancels the deletion.
+func (h *AuthHandler) startLoginSession(c *gin.Context, user models.User) {
+	// Past the grace period the account is only waiting for the purge job
+	if user.DeletionScheduledFor != nil && !user.DeletionScheduledFor.After(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deleted"})
+		return
+	}
+
+	h.loginSucceeded(c, user.Email)
+	cancelled := h.cancelAccountDeletion(c, &user)
+
+	tokenString, err := issueSession(h.db, c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "Login successful",
 		"token":   tokenString,
-		"user": gin.H{
-			"id":            user.ID,
-			"username":      user.Username,
-			"email":         user.Email,
-			"bio":           user.Bio,
-			"avatar":        user.Avatar,
-			"auth_provider": user.AuthProvider,
-		},
-	})
+		"user":    loginUserResponse(user),
+	}
+	if cancelled {
+		response["deletion_cancelled"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// loginUserResponse is the user payload returned after a successful login
+func loginUserResponse(user models.User) gin.H {
+	return gin.H{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"bio":            user.Bio,
+		"avatar":         user.Avatar,
+		"auth_provider":  user.AuthProvider,
+		"email_verified": user.EmailVerified,
+		"totp_enabled":   user.TOTPEnabled,
+	}
 }
 
 // GoogleLogin handles Google OAuth login
@@ -247,78 +255,12 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	result := h.db.Where("email = ? OR google_id = ?", googleUser.Email, googleUser.Sub).First(&user)
-
-	if result.Error == gorm.ErrRecordNotFound {
-		// Create new user from Google account
-		username := input.Username
-		if username == "" {
-			username = generateUsernameFromEmail(googleUser.Email)
-		}
-
-		// Ensure username is unique
-		username = h.ensureUniqueUsername(username)
-
-		avatar := input.Avatar
-		if avatar == "" && googleUser.Picture != "" {
-			avatar = googleUser.Picture
-		}
-
-		user = models.User{
-			Username:     username,
-			Email:        googleUser.Email,
-			Avatar:       avatar,
-			GoogleID:     googleUser.Sub,
-			AuthProvider: "google",
-			Password:     "", // No password for OAuth users
-		}
-
-		if err := h.db.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	} else {
-		// Existing user - update Google ID if not set
-		if user.GoogleID == "" {
-			user.GoogleID = googleUser.Sub
-			h.db.Save(&user)
-		}
-		// Update avatar if provided and user doesn't have one
-		if input.Avatar != "" && user.Avatar == "" {
-			user.Avatar = input.Avatar
-			h.db.Save(&user)
-		}
-	}
-
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(72 * time.Hour).Unix(),
-	})
-
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+	avatar := input.Avatar
+	if avatar == "" && googleUser.Picture != "" {
+		avatar = googleUser.Picture
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":            user.ID,
-			"username":      user.Username,
-			"email":         user.Email,
-			"avatar":        user.Avatar,
-			"bio":           user.Bio,
-			"auth_provider": user.AuthProvider,
-		},
-	})
+	h.oauthLogin(c, models.ProviderGoogle, googleUser.Sub, googleUser.Email, input.Username, avatar)
 }
 
 // AppleLogin handles Apple Sign In
@@ -341,73 +283,84 @@ func (h *AuthHandler) AppleLogin(c *gin.Context) {
 		return
 	}
 
+	h.oauthLogin(c, models.ProviderApple, appleUser.Sub, appleUser.Email, input.Username, input.Avatar)
+}
+
+// oauthLogin signs in the user linked to a provider identity, creating a
+// new account on first sign-in. An existing account with the same email is
+// never linked automatically: its owner has to log in and link explicitly.
+func (h *AuthHandler) oauthLogin(c *gin.Context, provider, subject, email, username, avatar string) {
 	var user models.User
-	result := h.db.Where("email = ? OR apple_id = ?", appleUser.Email, appleUser.Sub).First(&user)
 
-	if result.Error == gorm.ErrRecordNotFound {
-		// Create new user from Apple account
-		username := input.Username
+	var identity models.UserIdentity
+	err := h.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+
+	if err == nil {
+		if err := h.db.First(&user, identity.UserID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// Update avatar if provided and user doesn't have one
+		if avatar != "" && user.Avatar == "" {
+			user.Avatar = avatar
+			h.db.Save(&user)
+		}
+	} else if err == gorm.ErrRecordNotFound {
+		var count int64
+		h.db.Model(&models.User{}).Where("email = ?", email).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":    fmt.Sprintf("An account with this email already exists. Log in to it and link your %s account from your settings.", providerName(provider)),
+				"code":     "account_exists",
+				"provider": provider,
+			})
+			return
+		}
+
+		// Create new user from the provider account
 		if username == "" {
-			username = generateUsernameFromEmail(appleUser.Email)
+			username = generateUsernameFromEmail(email)
 		}
 
 		// Ensure username is unique
 		username = h.ensureUniqueUsername(username)
 
+		now := time.Now()
 		user = models.User{
 			Username:     username,
-			Email:        appleUser.Email,
-			Avatar:       input.Avatar,
-			AppleID:      appleUser.Sub,
-			AuthProvider: "apple",
+			Email:        email,
+			Avatar:       avatar,
+			AuthProvider: provider,
 			Password:     "", // No password for OAuth users
+
+			// Google and Apple only hand out verified addresses
+			EmailVerified:   true,
+			EmailVerifiedAt: &now,
 		}
 
-		if err := h.db.Create(&user).Error; err != nil {
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.UserIdentity{
+				UserID:   user.ID,
+				Provider: provider,
+				Subject:  subject,
+				Email:    email,
+			}).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
-	} else if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
 	} else {
-		// Existing user - update Apple ID if not set
-		if user.AppleID == "" {
-			user.AppleID = appleUser.Sub
-			h.db.Save(&user)
-		}
-		// Update avatar if provided and user doesn't have one
-		if input.Avatar != "" && user.Avatar == "" {
-			user.Avatar = input.Avatar
-			h.db.Save(&user)
-		}
-	}
-
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(72 * time.Hour).Unix(),
-	})
-
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":            user.ID,
-			"username":      user.Username,
-			"email":         user.Email,
-			"avatar":        user.Avatar,
-			"bio":           user.Bio,
-			"auth_provider": user.AuthProvider,
-		},
-	})
+	// A linked provider replaces the password step, not the second factor
+	h.completeLogin(c, user)
 }
 
 // GetMe returns the current authenticated user
@@ -425,13 +378,16 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":            user.ID,
-		"username":      user.Username,
-		"email":         user.Email,
-		"bio":           user.Bio,
-		"avatar":        user.Avatar,
-		"auth_provider": user.AuthProvider,
-		"created_at":    user.CreatedAt,
+		"id":              user.ID,
+		"username":        user.Username,
+		"email":           user.Email,
+		"bio":             user.Bio,
+		"avatar":          user.Avatar,
+		"avatar_variants": avatarVariantURLs(h.db, user),
+		"auth_provider":   user.AuthProvider,
+		"email_verified":  user.EmailVerified,
+		"totp_enabled":    user.TOTPEnabled,
+		"created_at":      user.CreatedAt,
 	})
 }
 
@@ -451,8 +407,7 @@ func (h *AuthHandler) ensureUniqueUsername(baseUsername string) string {
 	counter := 1
 
 	for {
-		var existingUser models.User
-		if err := h.db.Where("username = ?", username).First(&existingUser).Error; err == gorm.ErrRecordNotFound {
+		if !usernameTaken(h.db, username, 0) {
 			// Username is available
 			return username
 		}
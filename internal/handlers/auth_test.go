@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/database"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/loginguard"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// testDB starts a Postgres container with the full schema
+func testDB(t *testing.T) *gorm.DB {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	container, err := tcpostgres.Run(ctx, "postgres:latest",
+		tcpostgres.WithDatabase("test"),
+		tcpostgres.WithUsername("user"),
+		tcpostgres.WithPassword("password"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(database.Models()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestOAuthLoginRequiresSecondFactor(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+	jwtSecret = []byte("test-secret")
+
+	h := NewAuthHandler(db, nil, loginguard.NewFromEnv(db), nil)
+
+	link := func(user models.User, subject string) {
+		t.Helper()
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		identity := models.UserIdentity{UserID: user.ID, Provider: models.ProviderGoogle, Subject: subject, Email: user.Email}
+		if err := db.Create(&identity).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	link(models.User{Username: "totp", Email: "totp@example.com", Password: "hash", TOTPSecret: "JBSWY3DPEHPK3PXP", TOTPEnabled: true}, "google-totp")
+	link(models.User{Username: "plain", Email: "plain@example.com", Password: "hash"}, "google-plain")
+
+	login := func(subject, email string) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/google", nil)
+
+		h.oauthLogin(c, models.ProviderGoogle, subject, email, "", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("login as %s: status %d: %s", email, w.Code, w.Body.String())
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	body := login("google-totp", "totp@example.com")
+	if body["mfa_required"] != true || body["mfa_token"] == nil {
+		t.Errorf("TOTP account: expected an MFA challenge, got %v", body)
+	}
+	if _, ok := body["token"]; ok {
+		t.Errorf("TOTP account: got a session token without a second factor")
+	}
+
+	body = login("google-plain", "plain@example.com")
+	if body["token"] == nil || body["mfa_required"] != nil {
+		t.Errorf("account without 2FA: expected a session, got %v", body)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// blockedUserIDs returns the IDs of the users someone has blocked
+func blockedUserIDs(db *gorm.DB, userID int) []int {
+	var ids []int
+	if userID == 0 {
+		return ids
+	}
+	db.Model(&models.Block{}).Where("blocker_id = ?", userID).Pluck("blocked_id", &ids)
+	return ids
+}
+
+// hasBlocked reports whether blocker has blocked the other user
+func hasBlocked(db *gorm.DB, blockerID, blockedID int) bool {
+	var count int64
+	db.Model(&models.Block{}).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Count(&count)
+	return count > 0
+}
+
+// blockedEitherWay reports whether either user has blocked the other
+func blockedEitherWay(db *gorm.DB, a, b int) bool {
+	return hasBlocked(db, a, b) || hasBlocked(db, b, a)
+}
+
+// GetBlocks lists the users the current user has blocked
+func (h *UserHandler) GetBlocks(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var blocks []models.Block
+	if err := h.db.Where("blocker_id = ?", userID).Preload("Blocked").Order("created_at desc").Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+
+	responses := make([]gin.H, 0, len(blocks))
+	for _, block := range blocks {
+		responses = append(responses, gin.H{
+			"id":         block.Blocked.ID,
+			"username":   block.Blocked.Username,
+			"avatar":     block.Blocked.Avatar,
+			"blocked_at": block.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// BlockUser blocks a user by ID or username and removes any follows
+// between the two accounts
+func (h *UserHandler) BlockUser(c *gin.Context) {
+	var input struct {
+		UserID   int    `json:"user_id"`
+		Username string `json:"username"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil || (input.UserID == 0 && input.Username == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or username is required"})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var target models.User
+	query := h.db.Where("id = ?", input.UserID)
+	if input.UserID == 0 {
+		query = h.db.Where("username = ?", input.Username)
+	}
+	if err := query.First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if target.ID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+		return
+	}
+
+	if hasBlocked(h.db, userID, target.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already blocked this user"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Block{BlockerID: userID, BlockedID: target.ID}).Error; err != nil {
+			return err
+		}
+		return tx.Where("(follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)",
+			userID, target.ID, target.ID, userID).Delete(&models.Follow{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "User blocked",
+		"user": gin.H{
+			"id":       target.ID,
+			"username": target.Username,
+			"avatar":   target.Avatar,
+		},
+	})
+}
+
+// UnblockUser removes a block. Follows removed by the block are not restored.
+func (h *UserHandler) UnblockUser(c *gin.Context) {
+	blockedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result := h.db.Where("blocker_id = ? AND blocked_id = ?", userID, blockedID).Delete(&models.Block{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This user is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/emilythestrangee/reddit-clone/backend/internal/markdown"
 	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
 )
 
@@ -46,24 +48,59 @@ func (h *CommentHandler) GetComments(c *gin.Context) {
 	postID := c.Param("id")
 	var comments []models.Comment
 
-	if err := h.db.Where("post_id = ?", postID).Preload("User").Order("created_at desc").Find(&comments).Error; err != nil {
+	sort, err := resolveSort(c, h.db, func(p models.UserPreferences) string { return p.CommentSort },
+		models.CommentSortTop, models.CommentSortNew, models.CommentSortOld)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of top, new or old"})
+		return
+	}
+
+	order := "created_at DESC"
+	switch sort {
+	case models.CommentSortTop:
+		order = "(SELECT COALESCE(SUM(vote_type), 0) FROM votes WHERE votes.comment_id = comments.id) DESC, created_at ASC"
+	case models.CommentSortOld:
+		order = "created_at ASC"
+	}
+
+	if err := h.db.Where("post_id = ?", postID).Preload("User").Order(order).Find(&comments).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
 	}
 
+	// Comments from users the viewer has blocked stay in the thread but are collapsed
+	blocked := map[int]bool{}
+	viewerID, ok := extractUserID(c)
+	if ok {
+		for _, id := range blockedUserIDs(h.db, viewerID) {
+			blocked[id] = true
+		}
+	}
+
+	commentIDs := make([]int, 0, len(comments))
+	for _, comment := range comments {
+		commentIDs = append(commentIDs, comment.ID)
+	}
+	saved := savedIDs(h.db, viewerID, "comment_id", commentIDs)
+
 	var responses []gin.H
 	for _, comment := range comments {
 		up, down := h.calculateCommentVotes(comment.ID)
 		responses = append(responses, gin.H{
 			"id":         comment.ID,
 			"body":       comment.Body,
+			"body_html":  markdown.Render(comment.Body),
 			"author_id":  comment.AuthorID,
 			"post_id":    comment.PostID,
 			"user":       comment.User,
 			"upvotes":    up,
 			"downvotes":  down,
+			"collapsed":  blocked[authorIDOf(comment.AuthorID)],
+			"saved":      saved[comment.ID],
 			"created_at": comment.CreatedAt,
 			"updated_at": comment.UpdatedAt,
+			"edited":     comment.EditedAt != nil,
+			"edited_at":  comment.EditedAt,
 		})
 	}
 
@@ -113,10 +150,15 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
+	if hasBlocked(h.db, authorIDOf(post.UserID), authorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't reply to this user"})
+		return
+	}
+
 	comment := models.Comment{
 		Body:     input.Body,
 		PostID:   post.ID,
-		AuthorID: authorID,
+		AuthorID: &authorID,
 	}
 
 	if err := h.db.Create(&comment).Error; err != nil {
@@ -152,13 +194,29 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	if comment.AuthorID != authorID {
+	if !comment.IsAuthor(authorID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
 		return
 	}
 
-	comment.Body = input.Body
-	h.db.Save(&comment)
+	if input.Body != comment.Body {
+		original := models.Revision{CommentID: &comment.ID, EditorID: authorIDOf(comment.AuthorID), Body: comment.Body, CreatedAt: comment.CreatedAt}
+		now := time.Now()
+		comment.Body = input.Body
+		comment.EditedAt = &now
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&comment).Error; err != nil {
+				return err
+			}
+			return recordRevision(tx, "comment_id", comment.ID, original, models.Revision{
+				CommentID: &comment.ID, EditorID: authorID, Body: comment.Body, CreatedAt: now,
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+	}
 	h.db.Preload("User").First(&comment, comment.ID)
 
 	up, down := h.calculateCommentVotes(comment.ID)
@@ -172,6 +230,8 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		"downvotes":  down,
 		"created_at": comment.CreatedAt,
 		"updated_at": comment.UpdatedAt,
+		"edited":     comment.EditedAt != nil,
+		"edited_at":  comment.EditedAt,
 	})
 }
 
@@ -191,13 +251,15 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if comment.AuthorID != authorID {
+	if !comment.IsAuthor(authorID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
 		return
 	}
 
-	// Clean up votes on this comment too
+	// Clean up votes, edit history and saves of this comment too
 	h.db.Where("comment_id = ?", comment.ID).Delete(&models.Vote{})
+	h.db.Where("comment_id = ?", comment.ID).Delete(&models.Revision{})
+	h.db.Where("comment_id = ?", comment.ID).Delete(&models.SavedItem{})
 
 	if err := h.db.Delete(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// Community names follow the same rules as c/name mentions
+var communityNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,30}$`)
+
+type CommunityHandler struct {
+	db *gorm.DB
+}
+
+func NewCommunityHandler(db *gorm.DB) *CommunityHandler {
+	return &CommunityHandler{db: db}
+}
+
+// GetCommunity returns a community by name
+func (h *CommunityHandler) GetCommunity(c *gin.Context) {
+	var community models.Community
+	if err := h.db.Where("name = ?", c.Param("name")).First(&community).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Community not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, community)
+}
+
+// CreateCommunity creates a community. Only moderators can create them.
+func (h *CommunityHandler) CreateCommunity(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if !isModerator(h.db, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can create communities"})
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description" binding:"max=500"`
+		NSFW        bool   `json:"nsfw"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !communityNamePattern.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Community names are 3 to 30 letters, digits, _ and -"})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Community{}).Where("LOWER(name) = LOWER(?)", input.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A community with that name already exists"})
+		return
+	}
+
+	community := models.Community{Name: input.Name, Description: input.Description, NSFW: input.NSFW}
+	if err := h.db.Create(&community).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create community"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, community)
+}
+
+// UpdateCommunity changes a community's description or NSFW flag. Only
+// moderators can change them.
+func (h *CommunityHandler) UpdateCommunity(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if !isModerator(h.db, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can change communities"})
+		return
+	}
+
+	var input struct {
+		Description *string `json:"description" binding:"omitempty,max=500"`
+		NSFW        *bool   `json:"nsfw"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var community models.Community
+	if err := h.db.Where("name = ?", c.Param("name")).First(&community).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Community not found"})
+		return
+	}
+
+	setIfPresent(&community.Description, input.Description)
+	setIfPresent(&community.NSFW, input.NSFW)
+	if err := h.db.Save(&community).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update community"})
+		return
+	}
+
+	c.JSON(http.StatusOK, community)
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/dataexport"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const (
+	// How long a finished archive is kept
+	dataExportTTL = 7 * 24 * time.Hour
+	// Minimum time between two exports for the same user
+	dataExportInterval = 24 * time.Hour
+	// Lifetime of a download link
+	dataExportLinkTTL = 15 * time.Minute
+)
+
+// dataExportDir is where archives are written, set with EXPORT_DIR
+func dataExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "./tmp/exports"
+}
+
+// issueDataExportToken signs a short-lived download link for an export
+func issueDataExportToken(export models.DataExport) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"export_id": export.ID,
+		"user_id":   export.UserID,
+		"typ":       "data_export",
+		"exp":       time.Now().Add(dataExportLinkTTL).Unix(),
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// parseDataExportToken validates a download token for the given export
+func parseDataExportToken(raw string, exportID int) (int, error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != "data_export" {
+		return 0, errInvalidToken
+	}
+
+	id, _ := claims["export_id"].(float64)
+	userID, ok := claims["user_id"].(float64)
+	if !ok || int(id) != exportID {
+		return 0, errInvalidToken
+	}
+	return int(userID), nil
+}
+
+// dataExportResponse adds a fresh download link to finished exports
+func dataExportResponse(export models.DataExport) gin.H {
+	response := gin.H{
+		"id":           export.ID,
+		"status":       export.Status,
+		"size":         export.Size,
+		"created_at":   export.CreatedAt,
+		"completed_at": export.CompletedAt,
+		"expires_at":   export.ExpiresAt,
+	}
+
+	if export.Status == models.DataExportReady {
+		if token, err := issueDataExportToken(export); err == nil {
+			response["download_url"] = fmt.Sprintf("/api/exports/%d/download?token=%s", export.ID, token)
+			response["download_url_expires_at"] = time.Now().Add(dataExportLinkTTL)
+		}
+	}
+	return response
+}
+
+// buildDataExport writes the archive for an export and emails the user when
+// it's ready. It runs in the background after the request has returned.
+func (h *AccountHandler) buildDataExport(export models.DataExport, user models.User) {
+	fail := func(err error) {
+		log.Printf("Data export %d for user %d failed: %v", export.ID, user.ID, err)
+		h.db.Model(&export).Update("status", models.DataExportFailed)
+	}
+
+	result := h.db.Model(&models.DataExport{}).
+		Where("id = ? AND status = ?", export.ID, models.DataExportPending).
+		Update("status", models.DataExportProcessing)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	archive, err := dataexport.Collect(ctx, h.db, user.ID)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := os.MkdirAll(dataExportDir(), 0o700); err != nil {
+		fail(err)
+		return
+	}
+
+	// Random file name so archives can't be guessed from the export ID
+	name, _, err := generateToken()
+	if err != nil {
+		fail(err)
+		return
+	}
+	path := filepath.Join(dataExportDir(), name+".zip")
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := archive.WriteZip(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		fail(err)
+		return
+	}
+	info, _ := f.Stat()
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		fail(err)
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(dataExportTTL)
+	err = h.db.Model(&export).Updates(map[string]interface{}{
+		"status":       models.DataExportReady,
+		"file_path":    path,
+		"size":         info.Size(),
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error
+	if err != nil {
+		os.Remove(path)
+		fail(err)
+		return
+	}
+
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe copy of your data you asked for is ready. Download it from your account settings:\n\n%s/settings/privacy\n\nIt will be deleted on %s.\n",
+			user.Username, appURL(), expiresAt.Format("January 2, 2006"),
+		),
+	})
+}
+
+// RequestDataExport starts building an archive of the current user's data
+func (h *AccountHandler) RequestDataExport(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var latest models.DataExport
+	if err := h.db.Where("user_id = ? AND status <> ?", user.ID, models.DataExportFailed).Order("created_at desc").First(&latest).Error; err == nil {
+		if latest.Status == models.DataExportPending || latest.Status == models.DataExportProcessing {
+			c.JSON(http.StatusConflict, gin.H{"error": "An export is already being prepared", "export": dataExportResponse(latest)})
+			return
+		}
+		if wait := dataExportInterval - time.Since(latest.CreatedAt); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "You can request one data export per day"})
+			return
+		}
+	}
+
+	export := models.DataExport{UserID: user.ID, Status: models.DataExportPending}
+	if err := h.db.Create(&export).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start data export"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventDataExportRequested, "")
+	go h.buildDataExport(export, *user)
+
+	c.JSON(http.StatusAccepted, dataExportResponse(export))
+}
+
+// GetDataExports lists the current user's exports with download links for finished ones
+func (h *AccountHandler) GetDataExports(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var exports []models.DataExport
+	if err := h.db.Where("user_id = ?", userID).Order("created_at desc").Limit(10).Find(&exports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data exports"})
+		return
+	}
+
+	responses := make([]gin.H, 0, len(exports))
+	for _, export := range exports {
+		responses = append(responses, dataExportResponse(export))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// DownloadDataExport serves a finished archive. The signed link stands in
+// for the Authorization header so it works as a plain browser download.
+func (h *AccountHandler) DownloadDataExport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	userID, err := parseDataExportToken(c.Query("token"), id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Download link is invalid or has expired"})
+		return
+	}
+
+	var export models.DataExport
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&export).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+
+	if export.Status != models.DataExportReady || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "This export is no longer available"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(export.FilePath, fmt.Sprintf("data-export-%s.zip", export.CreatedAt.Format("2006-01-02")))
+}
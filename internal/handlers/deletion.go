@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// How long a deleted account can still be restored by logging in
+const accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+// DeleteAccount schedules the current user's account for deletion. The
+// password (or a fresh login for accounts without one) and, with 2FA on, a
+// second factor are required. All sessions are signed out; logging in again
+// during the grace period cancels the deletion.
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	var input struct {
+		Password string `json:"password"`
+		mfaCodes
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.DeletionScheduledFor != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account deletion is already scheduled"})
+		return
+	}
+
+	if !h.reauthenticate(c, user, input.Password, input.mfaCodes) {
+		return
+	}
+
+	now := time.Now()
+	scheduledFor := now.Add(accountDeletionGracePeriod)
+	err := h.db.Model(user).Updates(map[string]interface{}{
+		"deletion_requested_at":  now,
+		"deletion_scheduled_for": scheduledFor,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+
+	if err := revokeUserSessions(h.db, user.ID, ""); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", user.ID, err)
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventDeletionRequested, "")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour account is scheduled to be deleted on %s. Until then you can cancel the deletion simply by logging in.\n\nAfter that date your posts and comments will be shown as [deleted] and your account can't be recovered.\n",
+			user.Username, scheduledFor.Format("January 2, 2006"),
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Your account will be deleted. Log in again before then to cancel.",
+		"scheduled_for": scheduledFor,
+	})
+}
+
+// cancelAccountDeletion clears a pending deletion when the user logs in
+// during the grace period. It reports whether a deletion was cancelled.
+func (h *AuthHandler) cancelAccountDeletion(c *gin.Context, user *models.User) bool {
+	if user.DeletionScheduledFor == nil {
+		return false
+	}
+
+	// Conditional so a login can't resurrect an account the purge job already took
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_for > ?", user.ID, time.Now()).
+		Updates(map[string]interface{}{"deletion_requested_at": nil, "deletion_scheduled_for": nil})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.DeletionRequestedAt = nil
+	user.DeletionScheduledFor = nil
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventDeletionCancelled, "")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account deletion was cancelled",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYou logged in, so your account will no longer be deleted.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username,
+		),
+	})
+	return true
+}
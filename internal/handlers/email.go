@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+)
+
+// appURL returns the frontend base URL used in emailed links
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000" // local dev fallback
+}
+
+// sendEmail delivers an email in the background so slow mail servers
+// don't hold up the request. Failures are logged.
+func sendEmail(m mailer.Mailer, msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := m.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send email to %s: %v", msg.To, err)
+		}
+	}()
+}
@@ -1,15 +1,34 @@
 package handlers
 
 import (
+	"log"
+
 	"github.com/emilythestrangee/reddit-clone/backend/internal/database"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/loginguard"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/passkey"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/sms"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/unfurl"
 )
 
 // Handler combines all handler types
 type Handler struct {
-	Auth    *AuthHandler
-	Post    *PostHandler
-	Comment *CommentHandler
-	User    *UserHandler
+	Auth      *AuthHandler
+	Post      *PostHandler
+	Comment   *CommentHandler
+	User      *UserHandler
+	Account   *AccountHandler
+	MFA       *MFAHandler
+	Media     *MediaHandler
+	Saved     *SavedHandler
+	Community *CommunityHandler
+
+	// Storage holds uploaded media, shared with background jobs
+	Storage storage.Storage
+
+	// Unfurler fetches link previews, shared with background jobs
+	Unfurler *unfurl.Fetcher
 }
 
 // NewHandler creates a unified handler with all sub-handlers
@@ -18,10 +37,36 @@ func NewHandler(db *database.Database) *Handler {
 	dbService := database.New()
 	gormDB := dbService.GetDB()
 
+	// Shared email and SMS delivery
+	mail := mailer.NewFromEnv()
+	texts := sms.NewFromEnv()
+
+	// Brute-force protection shared by every login path
+	guard := loginguard.NewFromEnv(gormDB)
+
+	passkeys, err := passkey.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure passkeys: %v", err)
+	}
+
+	store, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure media storage: %v", err)
+	}
+
+	unfurler := unfurl.New()
+
 	return &Handler{
-		Auth:    NewAuthHandler(gormDB),
-		Post:    NewPostHandler(gormDB),
-		Comment: NewCommentHandler(gormDB),
-		User:    NewUserHandler(gormDB),
+		Auth:      NewAuthHandler(gormDB, mail, guard, passkeys),
+		Post:      NewPostHandler(gormDB, store, unfurler),
+		Comment:   NewCommentHandler(gormDB),
+		User:      NewUserHandler(gormDB),
+		Account:   NewAccountHandler(gormDB, mail),
+		MFA:       NewMFAHandler(gormDB, mail, texts),
+		Media:     NewMediaHandler(gormDB, store),
+		Saved:     NewSavedHandler(gormDB),
+		Community: NewCommunityHandler(gormDB),
+		Storage:   store,
+		Unfurler:  unfurler,
 	}
 }
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// hiddenPostIDs is a subquery of the posts a user has hidden
+func hiddenPostIDs(db *gorm.DB, userID int) *gorm.DB {
+	return db.Model(&models.HiddenPost{}).Select("post_id").Where("user_id = ?", userID)
+}
+
+// recordView adds a post to the viewer's read history unless they paused it
+func recordView(db *gorm.DB, userID, postID int) {
+	if userID == 0 || loadPreferences(db, userID).PauseHistory {
+		return
+	}
+	db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"viewed_at"}),
+	}).Create(&models.PostView{UserID: userID, PostID: postID, ViewedAt: time.Now()})
+}
+
+// HidePost removes a post from the current user's feeds
+func (h *PostHandler) HidePost(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || h.db.First(&models.Post{}, postID).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	hidden := models.HiddenPost{UserID: userID, PostID: postID}
+	if err := h.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&hidden).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hide post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hidden": true})
+}
+
+// UnhidePost puts a hidden post back in the current user's feeds
+func (h *PostHandler) UnhidePost(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.db.Where("user_id = ? AND post_id = ?", userID, c.Param("id")).Delete(&models.HiddenPost{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unhide post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hidden": false})
+}
+
+// GetHistory lists the posts the current user viewed, most recent first
+func (h *PostHandler) GetHistory(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, limit, ok := parsePage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page and limit must be positive numbers"})
+		return
+	}
+
+	query := h.db.Model(&models.PostView{}).Where("user_id = ?", userID)
+
+	var total int64
+	var views []models.PostView
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+	if err := query.Order("viewed_at desc, id desc").Offset((page - 1) * limit).Limit(limit).Find(&views).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+
+	postIDs := make([]int, 0, len(views))
+	for _, view := range views {
+		postIDs = append(postIDs, view.PostID)
+	}
+	posts := map[int]models.Post{}
+	var found []models.Post
+	if len(postIDs) > 0 {
+		preloadPostMedia(h.db).Preload("User").Where("id IN ?", postIDs).Find(&found)
+		for _, p := range found {
+			posts[p.ID] = p
+		}
+	}
+	nsfwCommunities := nsfwCommunityIDs(h.db, found)
+	prefs := loadPreferences(h.db, userID)
+
+	responses := make([]gin.H, 0, len(views))
+	for _, view := range views {
+		post, ok := posts[view.PostID]
+		if !ok {
+			continue
+		}
+		response := gin.H{
+			"id":         post.ID,
+			"title":      post.Title,
+			"kind":       post.Kind,
+			"url":        post.URL,
+			"domain":     post.Domain,
+			"image":      post.Image,
+			"media":      postMediaResponse(post.Media),
+			"community":  post.Community,
+			"user":       post.User,
+			"created_at": post.CreatedAt,
+		}
+		for k, v := range contentFlags(post, nsfwCommunities, prefs) {
+			response[k] = v
+		}
+		responses = append(responses, gin.H{"viewed_at": view.ViewedAt, "post": response})
+	}
+
+	c.JSON(http.StatusOK, pageResponse(responses, page, limit, total))
+}
+
+// ClearHistory deletes the current user's read history
+func (h *PostHandler) ClearHistory(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.db.Where("user_id = ?", userID).Delete(&models.PostView{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "History cleared"})
+}
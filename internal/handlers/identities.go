@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// providerName returns the display name of an identity provider
+func providerName(provider string) string {
+	switch provider {
+	case models.ProviderGoogle:
+		return "Google"
+	case models.ProviderApple:
+		return "Apple"
+	default:
+		return provider
+	}
+}
+
+// verifyProviderToken checks an ID token from the given provider and
+// returns the provider's subject and email
+func verifyProviderToken(provider, token string) (string, string, error) {
+	switch provider {
+	case models.ProviderGoogle:
+		user, err := verifyGoogleIDToken(token)
+		if err != nil {
+			return "", "", err
+		}
+		return user.Sub, user.Email, nil
+	case models.ProviderApple:
+		user, err := verifyAppleIDToken(token)
+		if err != nil {
+			return "", "", err
+		}
+		return user.Sub, user.Email, nil
+	default:
+		return "", "", fmt.Errorf("unsupported provider %q", provider)
+	}
+}
+
+// loginMethodCount counts the ways a user can still sign in
+func loginMethodCount(db *gorm.DB, user models.User) int64 {
+	var count, passkeys int64
+	db.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count)
+	db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", user.ID).Count(&passkeys)
+	count += passkeys
+	if user.Password != "" {
+		count++
+	}
+	return count
+}
+
+// GetIdentities lists the login providers linked to the current user
+func (h *AuthHandler) GetIdentities(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var identities []models.UserIdentity
+	h.db.Where("user_id = ?", user.ID).Order("created_at asc").Find(&identities)
+
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities":   identities,
+		"has_password": user.Password != "",
+	})
+}
+
+// LinkIdentity links a Google or Apple account to the logged-in user
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+	if provider != models.ProviderGoogle && provider != models.ProviderApple {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported provider"})
+		return
+	}
+
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	subject, email, err := verifyProviderToken(provider, input.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid %s token", providerName(provider))})
+		return
+	}
+
+	var existing models.UserIdentity
+	if err := h.db.Where("provider = ? AND subject = ?", provider, subject).First(&existing).Error; err == nil {
+		if existing.UserID == userID {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("This %s account is already linked", providerName(provider))})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("This %s account is linked to another user", providerName(provider))})
+		}
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.UserIdentity{}).Where("user_id = ? AND provider = ?", userID, provider).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s account is already linked, unlink it first", providerName(provider))})
+		return
+	}
+
+	identity := models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}
+	if err := h.db.Create(&identity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, userID, models.SecurityEventIdentityLinked, provider)
+
+	c.JSON(http.StatusCreated, identity)
+}
+
+// UnlinkIdentity removes a provider from the logged-in user, as long as
+// another way to sign in remains
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var identity models.UserIdentity
+	if err := h.db.Where("user_id = ? AND provider = ?", user.ID, provider).First(&identity).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No linked account for this provider"})
+		return
+	}
+
+	if loginMethodCount(h.db, user) <= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't remove your only way to sign in. Set a password with \"Forgot password\" or link another account first."})
+		return
+	}
+
+	if err := h.db.Delete(&identity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink account"})
+		return
+	}
+
+	// Clear the legacy column too so the backfill can't bring the link back
+	switch provider {
+	case models.ProviderGoogle:
+		h.db.Model(&user).Update("google_id", "")
+	case models.ProviderApple:
+		h.db.Model(&user).Update("apple_id", "")
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventIdentityUnlinked, provider)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("%s account unlinked", providerName(provider))})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const (
+	magicLinkTTL = 15 * time.Minute
+
+	// Minimum time between two magic links for the same account
+	magicLinkInterval = time.Minute
+)
+
+// RequestMagicLink emails a single-use login link. Like ForgotPassword it
+// always responds with 200 so registered emails can't be discovered.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Locked accounts and IPs can't get links either
+	if !h.loginAllowed(c, input.Email) {
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that email, a login link has been sent"}
+
+	var user models.User
+	if err := h.db.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Silently drop requests that come in too quickly
+	var recent int64
+	h.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, models.TokenPurposeMagicLink, time.Now().Add(-magicLinkInterval)).
+		Count(&recent)
+	if recent > 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := createUserToken(h.db, user.ID, models.TokenPurposeMagicLink, user.Email, magicLinkTTL)
+	if err != nil {
+		log.Printf("Failed to create magic link for user %d: %v", user.ID, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", appURL(), token)
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to log in:\n\n%s\n\nThe link expires in 15 minutes and can only be used once. If you didn't ask for it, you can ignore this email.\n",
+			user.Username, link,
+		),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConsumeMagicLink exchanges a login link for a session, going through the
+// same 2FA and throttling steps as a password login
+func (h *AuthHandler) ConsumeMagicLink(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the guard before burning the token so a locked-out user can retry later
+	var pending models.UserToken
+	if err := h.db.Where("token_hash = ? AND purpose = ?", hashToken(input.Token), models.TokenPurposeMagicLink).First(&pending).Error; err == nil {
+		if !h.loginAllowed(c, pending.Email) {
+			return
+		}
+	}
+
+	token, err := consumeUserToken(h.db, input.Token, models.TokenPurposeMagicLink)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, token.UserID).Error; err != nil || user.Email != token.Email {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+		return
+	}
+
+	// Following the link proves the user controls the address
+	if !user.EmailVerified {
+		now := time.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		h.db.Model(&user).Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now})
+	}
+
+	h.completeLogin(c, user)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/jobs"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+)
+
+// Upload types we accept, keyed by sniffed content type
+var allowedMediaTypes = map[string]struct {
+	kind string
+	ext  string
+}{
+	"image/jpeg": {models.MediaKindImage, ".jpg"},
+	"image/png":  {models.MediaKindImage, ".png"},
+	"image/gif":  {models.MediaKindImage, ".gif"},
+	"image/webp": {models.MediaKindImage, ".webp"},
+	"video/mp4":  {models.MediaKindVideo, ".mp4"},
+	"video/webm": {models.MediaKindVideo, ".webm"},
+}
+
+type MediaHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+}
+
+func NewMediaHandler(db *gorm.DB, store storage.Storage) *MediaHandler {
+	return &MediaHandler{db: db, storage: store}
+}
+
+// mediaBaseURL is where stored files are served from, set with
+// MEDIA_BASE_URL to serve them from a CDN or the bucket directly
+func mediaBaseURL() string {
+	if url := os.Getenv("MEDIA_BASE_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "/media"
+}
+
+// mediaURL is the public URL of a stored file
+func mediaURL(key string) string {
+	return mediaBaseURL() + "/" + key
+}
+
+// mediaSizeLimit returns the largest upload allowed for a kind, in bytes
+func mediaSizeLimit(kind string) int64 {
+	name, fallback := "MEDIA_MAX_IMAGE_MB", int64(20)
+	if kind == models.MediaKindVideo {
+		name, fallback = "MEDIA_MAX_VIDEO_MB", 200
+	}
+	if mb, err := strconv.ParseInt(os.Getenv(name), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return fallback << 20
+}
+
+func mediaResponse(media models.Media) gin.H {
+	return gin.H{
+		"id":           media.ID,
+		"kind":         media.Kind,
+		"url":          mediaURL(media.Key),
+		"content_type": media.ContentType,
+		"size":         media.Size,
+		"status":       media.Status,
+		"width":        media.Width,
+		"height":       media.Height,
+		"variants":     mediaVariantURLs(media.Variants),
+		"created_at":   media.CreatedAt,
+	}
+}
+
+// mediaVariantURLs maps variant names such as "thumbnail" to their URLs.
+// It is empty until the upload has been processed.
+func mediaVariantURLs(variants []models.MediaVariant) map[string]string {
+	urls := make(map[string]string, len(variants))
+	for _, v := range variants {
+		urls[v.Name] = mediaURL(v.Key)
+	}
+	return urls
+}
+
+// avatarVariantURLs returns the variant URLs of a user's uploaded avatar,
+// or nil if the avatar isn't an upload
+func avatarVariantURLs(db *gorm.DB, user models.User) map[string]string {
+	if user.AvatarMediaID == nil {
+		return nil
+	}
+	var variants []models.MediaVariant
+	db.Where("media_id = ?", *user.AvatarMediaID).Find(&variants)
+	return mediaVariantURLs(variants)
+}
+
+// findOwnMedia loads an upload belonging to the user
+func findOwnMedia(db *gorm.DB, userID, mediaID int) (*models.Media, error) {
+	var media models.Media
+	if err := db.Where("id = ? AND user_id = ?", mediaID, userID).First(&media).Error; err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// UploadMedia stores an image or video from the multipart "file" field. The
+// type is sniffed from the content; the client's Content-Type is ignored.
+func (h *MediaHandler) UploadMedia(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Large videos take longer than the server's default read timeout
+	rc := http.NewResponseController(c.Writer)
+	rc.SetReadDeadline(time.Now().Add(10 * time.Minute))
+	rc.SetWriteDeadline(time.Now().Add(11 * time.Minute))
+
+	// Stop reading once the body is larger than any upload could be
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, mediaSizeLimit(models.MediaKindVideo)+1<<20)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the \"file\" field"})
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	allowed, ok := allowedMediaTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only JPEG, PNG, GIF and WebP images and MP4 and WebM videos can be uploaded"})
+		return
+	}
+
+	if limit := mediaSizeLimit(allowed.kind); header.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Files of this type are limited to %d MB", limit>>20)})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	name, _, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	key := fmt.Sprintf("%d/%s%s", userID, name, allowed.ext)
+
+	if err := h.storage.Put(c.Request.Context(), key, file, header.Size, contentType); err != nil {
+		log.Printf("Failed to store upload for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	media := models.Media{
+		UserID:      userID,
+		Kind:        allowed.kind,
+		Key:         key,
+		ContentType: contentType,
+		Size:        header.Size,
+		Status:      models.MediaStatusReady,
+	}
+	if media.Kind == models.MediaKindImage {
+		media.Status = models.MediaStatusPending
+	}
+	if err := h.db.Create(&media).Error; err != nil {
+		h.storage.Delete(c.Request.Context(), key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	// Metadata stripping and resizing happen in the background; until then
+	// the upload isn't served and has no variants
+	if media.Kind == models.MediaKindImage {
+		go jobs.ProcessMedia(context.Background(), h.db, h.storage, media.ID)
+	}
+
+	c.JSON(http.StatusCreated, mediaResponse(media))
+}
+
+// ServeMedia streams a stored file. Keys are random, so files are public
+// to anyone who has the URL. Images are only served once their metadata
+// has been stripped.
+func (h *MediaHandler) ServeMedia(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	// Variants have no media row of their own and only exist once processed
+	var media models.Media
+	if err := h.db.Where("key = ?", key).Limit(1).Find(&media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	switch media.Status {
+	case models.MediaStatusPending, models.MediaStatusProcessing:
+		c.JSON(http.StatusConflict, gin.H{"error": "File is still being processed"})
+		return
+	case models.MediaStatusFailed:
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	obj, err := h.storage.Open(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open media %q: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer obj.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, obj, map[string]string{
+		"Cache-Control":           "public, max-age=31536000, immutable",
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+	})
+}
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/sms"
+)
+
+const (
+	// Lifetime of the token handed out between password and second factor
+	mfaTokenTTL = 5 * time.Minute
+
+	recoveryCodeCount = 10
+	totpPeriod        = 30
+)
+
+type MFAHandler struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+	sms    sms.SMSSender
+}
+
+func NewMFAHandler(db *gorm.DB, m mailer.Mailer, s sms.SMSSender) *MFAHandler {
+	return &MFAHandler{db: db, mailer: m, sms: s}
+}
+
+// mfaCodes are the second-factor fields accepted wherever 2FA is checked
+type mfaCodes struct {
+	Code         string `json:"code"`     // TOTP code from an authenticator app
+	SMSCode      string `json:"sms_code"` // Code sent by SMS
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// mfaIssuer is the name shown in authenticator apps
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Reddit Clone"
+}
+
+// issueMFAToken returns a short-lived token proving the password step
+// succeeded. It has no session so AuthMiddleware rejects it.
+func issueMFAToken(user models.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"typ":     "mfa_pending",
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// parseMFAToken validates an "mfa pending" token and returns its user ID
+func parseMFAToken(raw string) (int, error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != "mfa_pending" {
+		return 0, errInvalidToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errInvalidToken
+	}
+	return int(userID), nil
+}
+
+// mfaMethods lists the second factors the user can complete login with.
+// Passkeys are accepted once 2FA is on but don't turn it on by themselves.
+func mfaMethods(db *gorm.DB, user models.User) []string {
+	methods := []string{}
+	if !requiresMFA(user) {
+		return methods
+	}
+	if user.TOTPEnabled {
+		methods = append(methods, "totp")
+	}
+	if user.SMSMFAEnabled && user.PhoneVerified {
+		methods = append(methods, "sms")
+	}
+	if hasPasskeys(db, user.ID) {
+		methods = append(methods, "passkey")
+	}
+	return append(methods, "recovery_code")
+}
+
+// requiresMFA reports whether login must be completed with a second factor
+func requiresMFA(user models.User) bool {
+	return user.TOTPEnabled || (user.SMSMFAEnabled && user.PhoneVerified)
+}
+
+// verifyTOTP checks a code against the user's secret, accepting one step of
+// clock drift. Each time step can only be used once.
+func verifyTOTP(db *gorm.DB, user *models.User, code string) bool {
+	if user.TOTPSecret == "" {
+		return false
+	}
+
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	now := time.Now()
+	code = strings.TrimSpace(code)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		step := now.Unix()/totpPeriod + offset
+		expected, err := totp.GenerateCodeCustom(user.TOTPSecret, time.Unix(step*totpPeriod, 0), opts)
+		if err != nil || subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		// Conditional update so a code can't be replayed, even concurrently
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false
+		}
+		user.TOTPLastStep = step
+		return true
+	}
+	return false
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the new plain codes
+func generateRecoveryCodes(db *gorm.DB, userID int) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b)) // 16 characters
+		code := fmt.Sprintf("%s-%s-%s-%s", raw[0:4], raw[4:8], raw[8:12], raw[12:16])
+
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// useRecoveryCode redeems one of the user's unused recovery codes
+func useRecoveryCode(db *gorm.DB, userID int, code string) bool {
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// verifySecondFactor accepts a TOTP code, an SMS login code or a recovery code
+func verifySecondFactor(db *gorm.DB, c *gin.Context, user *models.User, codes mfaCodes) bool {
+	if user.TOTPEnabled && codes.Code != "" && verifyTOTP(db, user, codes.Code) {
+		return true
+	}
+	if user.SMSMFAEnabled && user.PhoneVerified && codes.SMSCode != "" &&
+		verifyPhoneCode(db, user.ID, models.PhoneCodePurposeLogin, codes.SMSCode) {
+		return true
+	}
+	if requiresMFA(*user) && codes.RecoveryCode != "" && useRecoveryCode(db, user.ID, codes.RecoveryCode) {
+		recordSecurityEvent(db, c, user.ID, models.SecurityEventRecoveryCodeUsed, "")
+		return true
+	}
+	return false
+}
+
+// VerifyMFA exchanges an "mfa pending" token and a second factor for a session
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		mfaCodes
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := parseMFAToken(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	// Second-factor guesses count against the same limits as passwords
+	if !h.loginAllowed(c, user.Email) {
+		return
+	}
+
+	if !verifySecondFactor(h.db, c, &user, input.mfaCodes) {
+		h.loginFailed(c, user.Email, &user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	h.startLoginSession(c, user)
+}
+
+// GetMFAStatus returns the current user's two-factor settings
+func (h *MFAHandler) GetMFAStatus(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var remaining int64
+	h.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled":             user.TOTPEnabled,
+		"sms_enabled":              user.SMSMFAEnabled,
+		"phone_verified":           user.PhoneVerified,
+		"phone":                    maskPhone(user.Phone),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTOTP starts enrolment by generating a secret. 2FA stays off until
+// the user confirms a code from their authenticator app.
+func (h *MFAHandler) SetupTOTP(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is only available for accounts with a password"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      mfaIssuer(),
+		AccountName: user.Email,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := h.db.Model(&user).Updates(map[string]interface{}{"totp_secret": key.Secret(), "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrolment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      key.Secret(),
+		"otpauth_uri": key.URL(),
+	})
+}
+
+// ConfirmTOTP finishes enrolment and returns the recovery codes (shown once)
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	if !verifyTOTP(h.db, &user, input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := generateRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := h.db.Model(&user).Update("totp_enabled", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventMFAEnabled, "totp")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Two-factor authentication enabled",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nTwo-factor authentication was turned on for your account.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns 2FA off. Requires the password and a valid second factor.
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		mfaCodes
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !verifyPassword(h.db, &user, input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if !verifySecondFactor(h.db, c, &user, input.mfaCodes) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Recovery codes are still needed while SMS 2FA is on
+		if !user.SMSMFAEnabled {
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": ""}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventMFADisabled, "totp")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Two-factor authentication disabled",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nTwo-factor authentication was turned off for your account.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. Requires a second factor.
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var input mfaCodes
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !requiresMFA(user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !verifySecondFactor(h.db, c, &user, input) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := generateRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventRecoveryCodesReset, "")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// excludeNSFW filters NSFW posts, and posts in NSFW communities, out of a
+// posts query
+func excludeNSFW(query *gorm.DB) *gorm.DB {
+	return query.Where("posts.nsfw = ? AND NOT EXISTS (SELECT 1 FROM communities WHERE communities.id = posts.community_id AND communities.nsfw)", false)
+}
+
+// nsfwCommunityIDs returns the IDs of the posts' communities that are marked NSFW
+func nsfwCommunityIDs(db *gorm.DB, posts []models.Post) map[int]bool {
+	nsfw := map[int]bool{}
+	var ids []int
+	for _, post := range posts {
+		if post.CommunityID != 0 {
+			ids = append(ids, post.CommunityID)
+		}
+	}
+	if len(ids) == 0 {
+		return nsfw
+	}
+	var found []int
+	db.Model(&models.Community{}).Where("id IN ? AND nsfw", ids).Pluck("id", &found)
+	for _, id := range found {
+		nsfw[id] = true
+	}
+	return nsfw
+}
+
+// contentFlags marks NSFW and spoiler posts. blur tells clients to hide
+// the body and media until clicked; media has a "blurred" variant for this.
+// NSFW posts are blurred for viewers who haven't opted in to NSFW content.
+func contentFlags(post models.Post, nsfwCommunities map[int]bool, prefs models.UserPreferences) gin.H {
+	nsfw := post.NSFW || nsfwCommunities[post.CommunityID]
+	return gin.H{
+		"nsfw":    nsfw,
+		"spoiler": post.Spoiler,
+		"blur":    (nsfw && !prefs.ShowNSFW) || (post.Spoiler && prefs.BlurSpoilers),
+	}
+}
+
+// SetPostFlags marks a post NSFW or as a spoiler. The author and
+// moderators can change the flags; it doesn't count as an edit.
+func (h *PostHandler) SetPostFlags(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input struct {
+		NSFW    *bool `json:"nsfw"`
+		Spoiler *bool `json:"spoiler"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.NSFW == nil && input.Spoiler == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nsfw or spoiler is required"})
+		return
+	}
+
+	var post models.Post
+	if err := h.db.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if !post.IsAuthor(userID) && !isModerator(h.db, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author and moderators can change a post's flags"})
+		return
+	}
+
+	setIfPresent(&post.NSFW, input.NSFW)
+	setIfPresent(&post.Spoiler, input.Spoiler)
+	if err := h.db.Model(&post).Select("nsfw", "spoiler").Updates(&post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+
+	flags := contentFlags(post, nsfwCommunityIDs(h.db, []models.Post{post}), loadPreferences(h.db, userID))
+	flags["id"] = post.ID
+	c.JSON(http.StatusOK, flags)
+}
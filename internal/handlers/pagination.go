@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes for paginated lists
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+)
+
+// parsePage reads the page (from 1) and limit query parameters
+func parsePage(c *gin.Context) (page, limit int, ok bool) {
+	page, limit = 1, defaultPageSize
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		page = n
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		limit = min(n, maxPageSize)
+	}
+	return page, limit, true
+}
+
+// pageResponse wraps one page of a list with what clients need to fetch the next
+func pageResponse(items []gin.H, page, limit int, total int64) gin.H {
+	return gin.H{
+		"items":    items,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+		"has_more": int64(page*limit) < total,
+	}
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/passkey"
+)
+
+const (
+	// How long the browser has to complete a ceremony
+	webauthnSessionTTL = 5 * time.Minute
+
+	maxPasskeyNameLength = 64
+)
+
+// toWebAuthnCredential converts a stored passkey for the webauthn library
+func toWebAuthnCredential(stored models.WebAuthnCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, t := range strings.Split(stored.Transports, ",") {
+		if t != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              stored.CredentialID,
+		PublicKey:       stored.PublicKey,
+		AttestationType: stored.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: stored.BackupEligible,
+			BackupState:    stored.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    stored.AAGUID,
+			SignCount: stored.SignCount,
+		},
+	}
+}
+
+// loadPasskeyUser returns the user with their passkeys for a ceremony
+func loadPasskeyUser(db *gorm.DB, user models.User) (*passkey.User, error) {
+	var stored []models.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	pu := &passkey.User{ID: user.ID, Name: user.Username}
+	for _, cred := range stored {
+		pu.Credentials = append(pu.Credentials, toWebAuthnCredential(cred))
+	}
+	return pu, nil
+}
+
+// hasPasskeys reports whether the user has registered any passkey
+func hasPasskeys(db *gorm.DB, userID int) bool {
+	var count int64
+	db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// startWebAuthnSession stores a ceremony's challenge and returns the ID the
+// client sends back with the authenticator's response
+func startWebAuthnSession(db *gorm.DB, userID int, purpose string, data *webauthn.SessionData) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	raw, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	session := models.WebAuthnSession{
+		TokenHash: hash,
+		UserID:    userID,
+		Purpose:   purpose,
+		Data:      string(encoded),
+		ExpiresAt: time.Now().Add(webauthnSessionTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeWebAuthnSession deletes a ceremony session and returns its data.
+// A challenge can only ever be answered once.
+func consumeWebAuthnSession(db *gorm.DB, raw string, userID int, purpose string) (*webauthn.SessionData, error) {
+	var session models.WebAuthnSession
+	if err := db.Where("token_hash = ? AND user_id = ? AND purpose = ?", hashToken(raw), userID, purpose).First(&session).Error; err != nil {
+		return nil, errInvalidToken
+	}
+
+	result := db.Where("id = ?", session.ID).Delete(&models.WebAuthnSession{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(session.ExpiresAt) {
+		return nil, errInvalidToken
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// recordPasskeyUse stores the new signature counter after a successful assertion
+func recordPasskeyUse(db *gorm.DB, userID int, credential *webauthn.Credential) {
+	err := db.Model(&models.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", userID, credential.ID).
+		Updates(map[string]interface{}{
+			"sign_count":   credential.Authenticator.SignCount,
+			"backup_state": credential.Flags.BackupState,
+			"last_used_at": time.Now(),
+		}).Error
+	if err != nil {
+		log.Printf("Failed to update passkey for user %d: %v", userID, err)
+	}
+}
+
+// passkeyAssertionFailed handles a rejected assertion. Cloned credentials
+// are recorded so the user can see and remove them.
+func (h *AuthHandler) passkeyAssertionFailed(c *gin.Context, user *models.User, err error) {
+	if user != nil {
+		if errors.Is(err, passkey.ErrCloned) {
+			recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPasskeyCloned, "")
+		}
+		h.loginFailed(c, user.Email, user)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey verification failed"})
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	pu, err := loadPasskeyUser(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	options, data, err := h.passkeys.BeginRegistration(pu)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	sessionID, err := startWebAuthnSession(h.db, user.ID, models.WebAuthnPurposeRegistration, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"options":    options,
+	})
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores the passkey
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	var input struct {
+		SessionID  string          `json:"session_id" binding:"required"`
+		Name       string          `json:"name"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Name must be at most %d characters", maxPasskeyNameLength)})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	data, err := consumeWebAuthnSession(h.db, input.SessionID, user.ID, models.WebAuthnPurposeRegistration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey registration expired, please try again"})
+		return
+	}
+
+	pu, err := loadPasskeyUser(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	credential, err := h.passkeys.FinishRegistration(pu, *data, input.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey verification failed"})
+		return
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+
+	stored := models.WebAuthnCredential{
+		UserID:          user.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := h.db.Create(&stored).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This passkey is already registered"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPasskeyAdded, name)
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "A passkey was added to your account",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe passkey %q can now be used to sign in to your account.\n\nIf this wasn't you, remove it and reset your password immediately.\n",
+			user.Username, name,
+		),
+	})
+
+	c.JSON(http.StatusCreated, stored)
+}
+
+// GetPasskeys lists the current user's passkeys
+func (h *AuthHandler) GetPasskeys(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var passkeys []models.WebAuthnCredential
+	h.db.Where("user_id = ?", userID).Order("created_at asc").Find(&passkeys)
+
+	if passkeys == nil {
+		passkeys = []models.WebAuthnCredential{}
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+// RenamePasskey changes the display name of one of the current user's passkeys
+func (h *AuthHandler) RenamePasskey(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxPasskeyNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Name must be between 1 and %d characters", maxPasskeyNameLength)})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	var stored models.WebAuthnCredential
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&stored).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+		return
+	}
+
+	if err := h.db.Model(&stored).Update("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stored)
+}
+
+// DeletePasskey removes one of the current user's passkeys, as long as
+// another way to sign in remains
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var stored models.WebAuthnCredential
+	if err := h.db.Where("id = ? AND user_id = ?", id, user.ID).First(&stored).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+		return
+	}
+
+	if loginMethodCount(h.db, user) <= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't remove your only way to sign in. Set a password with \"Forgot password\" or link another account first."})
+		return
+	}
+
+	if err := h.db.Delete(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove passkey"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPasskeyRemoved, stored.Name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey removed"})
+}
+
+// BeginPasskeyLogin returns the options for a passwordless
+// navigator.credentials.get, where the authenticator picks the account
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	options, data, err := h.passkeys.BeginDiscoverableLogin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+
+	sessionID, err := startWebAuthnSession(h.db, 0, models.WebAuthnPurposeLogin, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"options":    options,
+	})
+}
+
+// FinishPasskeyLogin verifies a passwordless assertion and starts a session.
+// The passkey required user verification, so it already counts as two
+// factors and no further 2FA step is asked for.
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var input struct {
+		SessionID  string          `json:"session_id" binding:"required"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := consumeWebAuthnSession(h.db, input.SessionID, 0, models.WebAuthnPurposeLogin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey login expired, please try again"})
+		return
+	}
+
+	var user models.User
+	lookup := func(userID int) (*passkey.User, error) {
+		if err := h.db.First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		return loadPasskeyUser(h.db, user)
+	}
+
+	// The account is only known once the authenticator has answered, so
+	// the guard is checked here rather than before the ceremony
+	pu, credential, err := h.passkeys.FinishDiscoverableLogin(*data, input.Credential, lookup)
+	if pu == nil {
+		h.passkeyAssertionFailed(c, nil, err)
+		return
+	}
+	if !h.loginAllowed(c, user.Email) {
+		return
+	}
+	if err != nil {
+		h.passkeyAssertionFailed(c, &user, err)
+		return
+	}
+
+	recordPasskeyUse(h.db, user.ID, credential)
+	h.startLoginSession(c, user)
+}
+
+// BeginPasskeyMFA starts using a passkey as the second login step
+func (h *AuthHandler) BeginPasskeyMFA(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := parseMFAToken(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	pu, err := loadPasskeyUser(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+	if len(pu.Credentials) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No passkeys registered"})
+		return
+	}
+
+	options, data, err := h.passkeys.BeginLogin(pu)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey verification"})
+		return
+	}
+
+	sessionID, err := startWebAuthnSession(h.db, user.ID, models.WebAuthnPurposeMFA, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey verification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"options":    options,
+	})
+}
+
+// FinishPasskeyMFA completes a password login with a passkey assertion
+func (h *AuthHandler) FinishPasskeyMFA(c *gin.Context) {
+	var input struct {
+		MFAToken   string          `json:"mfa_token" binding:"required"`
+		SessionID  string          `json:"session_id" binding:"required"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := parseMFAToken(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	if !h.loginAllowed(c, user.Email) {
+		return
+	}
+
+	data, err := consumeWebAuthnSession(h.db, input.SessionID, user.ID, models.WebAuthnPurposeMFA)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey verification expired, please try again"})
+		return
+	}
+
+	pu, err := loadPasskeyUser(h.db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	credential, err := h.passkeys.FinishLogin(pu, *data, input.Credential)
+	if err != nil {
+		h.passkeyAssertionFailed(c, &user, err)
+		return
+	}
+
+	recordPasskeyUse(h.db, user.ID, credential)
+	h.startLoginSession(c, user)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const (
+	passwordResetTTL = time.Hour
+
+	// Minimum time between two reset emails for the same account
+	passwordResetInterval = time.Minute
+)
+
+// ForgotPassword emails a password reset link. Accounts that only sign in
+// with Google, Apple or a passkey use it to set a first password. It always
+// responds with 200 so the endpoint can't be used to find out which emails
+// are registered.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that email, a password reset link has been sent"}
+
+	// Password-less accounts need a verified address, so an unconfirmed
+	// email can't be used to add a password to someone else's account
+	var user models.User
+	if err := h.db.Where("email = ? AND (password <> '' OR email_verified)", input.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Silently drop requests that come in too quickly
+	var recent int64
+	h.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, models.TokenPurposePasswordReset, time.Now().Add(-passwordResetInterval)).
+		Count(&recent)
+	if recent > 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := createUserToken(h.db, user.ID, models.TokenPurposePasswordReset, user.Email, passwordResetTTL)
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %d: %v", user.ID, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", appURL(), token)
+	subject, intro := "Reset your password", "Someone asked to reset the password for your account. Open the link below to choose a new one:"
+	if user.Password == "" {
+		subject, intro = "Set a password", "Someone asked to set a password for your account, so you can sign in without Google, Apple or a passkey. Open the link below to choose one:"
+	}
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body: fmt.Sprintf(
+			"Hi %s,\n\n%s\n\n%s\n\nThe link expires in 1 hour and can only be used once. If you didn't ask for this, you can ignore this email.\n",
+			user.Username, intro, link,
+		),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs
+// the user out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the new password before redeeming the token so a rejected
+	// password can be retried with the same link
+	token, err := findUserToken(h.db, input.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, token.UserID).Error; err != nil || user.Email != token.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, input.Password, user.Username, user.Email) {
+		return
+	}
+
+	if _, err := consumeUserToken(h.db, input.Token, models.TokenPurposePasswordReset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := hashPassword(input.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user.Password = hashedPassword
+
+	// Following the link proves the user controls the address
+	if !user.EmailVerified {
+		now := time.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := h.db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Burn any other outstanding reset links and sign out every session
+	h.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, models.TokenPurposePasswordReset).
+		Update("used_at", time.Now())
+	if err := revokeUserSessions(h.db, user.ID, ""); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", user.ID, err)
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPasswordReset, "")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password for your account was just reset and all devices have been signed out.\n\nIf this wasn't you, reset your password again immediately and contact support.\n",
+			user.Username,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in with your new password"})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/password"
+)
+
+// passwordHasher is read from the environment on first use, after main has loaded .env
+var passwordHasher = sync.OnceValue(password.HasherFromEnv)
+
+// passwordPolicy is read from the environment on first use as well
+var passwordPolicy = sync.OnceValue(func() password.Policy {
+	policy := password.PolicyFromEnv()
+	// bcrypt can't hash more than 72 bytes
+	if passwordHasher().Algorithm == password.AlgorithmBcrypt && policy.MaxLength > 72 {
+		policy.MaxLength = 72
+	}
+	return policy
+})
+
+// checkPasswordPolicy validates a new password and responds with every
+// violation when it's rejected. It returns false if the request was handled.
+func checkPasswordPolicy(c *gin.Context, newPassword string, userInputs ...string) bool {
+	violations := passwordPolicy().Validate(newPassword, userInputs...)
+	if len(violations) == 0 {
+		return true
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "Password does not meet requirements",
+		"violations": violations,
+	})
+	return false
+}
+
+// hashPassword hashes a new password with the configured algorithm
+func hashPassword(plain string) (string, error) {
+	return passwordHasher().Hash(plain)
+}
+
+// verifyPassword checks a user's password. When it matches but the stored
+// hash uses an outdated algorithm or cost, the hash is upgraded in place.
+func verifyPassword(db *gorm.DB, user *models.User, plain string) bool {
+	if user.Password == "" {
+		return false
+	}
+
+	hasher := passwordHasher()
+	if err := hasher.Verify(plain, user.Password); err != nil {
+		if !errors.Is(err, password.ErrMismatch) {
+			log.Printf("Failed to verify password for user %d: %v", user.ID, err)
+		}
+		return false
+	}
+
+	if hasher.NeedsRehash(user.Password) {
+		hash, err := hasher.Hash(plain)
+		if err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+			return true
+		}
+		// Conditional so a concurrent password change isn't overwritten
+		result := db.Model(&models.User{}).
+			Where("id = ? AND password = ?", user.ID, user.Password).
+			Update("password", hash)
+		if result.Error != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, result.Error)
+		} else if result.RowsAffected == 1 {
+			user.Password = hash
+		}
+	}
+	return true
+}
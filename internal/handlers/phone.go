@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/sms"
+)
+
+const (
+	phoneCodeTTL         = 10 * time.Minute
+	phoneCodeMaxAttempts = 5
+
+	// Send limits per user
+	phoneCodeInterval   = time.Minute
+	phoneCodeMaxPerHour = 5
+)
+
+// phoneCodeHash ties a code to its user so equal codes don't share a hash
+func phoneCodeHash(userID int, code string) string {
+	return hashToken(fmt.Sprintf("%d:%s", userID, code))
+}
+
+// maskPhone hides all but the last digits of a phone number
+func maskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// phoneInUse reports whether another account has already verified the number
+func phoneInUse(db *gorm.DB, phone string, userID int) bool {
+	var count int64
+	db.Model(&models.User{}).Where("phone = ? AND phone_verified = ? AND id <> ?", phone, true, userID).Count(&count)
+	return count > 0
+}
+
+// isUniqueViolation reports whether a write failed on a unique index
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// phoneCodeRetryAfter returns how long the user must wait before another
+// code can be sent, or zero if sending is allowed
+func phoneCodeRetryAfter(db *gorm.DB, userID int) time.Duration {
+	var recent []models.PhoneCode
+	db.Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-time.Hour)).
+		Order("created_at desc").Find(&recent)
+
+	if len(recent) > 0 {
+		if wait := phoneCodeInterval - time.Since(recent[0].CreatedAt); wait > 0 {
+			return wait
+		}
+	}
+	if len(recent) >= phoneCodeMaxPerHour {
+		return time.Hour - time.Since(recent[len(recent)-1].CreatedAt)
+	}
+	return 0
+}
+
+// sendPhoneCode generates a 6-digit code, stores its hash and texts it to the phone
+func sendPhoneCode(db *gorm.DB, sender sms.SMSSender, userID int, phone, purpose string) error {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	// Only the newest code for a purpose is valid
+	db.Model(&models.PhoneCode{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now())
+
+	record := models.PhoneCode{
+		UserID:    userID,
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  phoneCodeHash(userID, code),
+		ExpiresAt: time.Now().Add(phoneCodeTTL),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	body := fmt.Sprintf("Your %s code is %s. It expires in 10 minutes.", mfaIssuer(), code)
+	return sender.Send(ctx, phone, body)
+}
+
+// verifyPhoneCode checks the latest code sent for a purpose. Each code
+// allows a limited number of attempts and can only be used once.
+func verifyPhoneCode(db *gorm.DB, userID int, purpose, code string) bool {
+	var record models.PhoneCode
+	err := db.Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, purpose, time.Now()).
+		Order("created_at desc").First(&record).Error
+	if err != nil || record.Attempts >= phoneCodeMaxAttempts {
+		return false
+	}
+
+	db.Model(&record).Update("attempts", gorm.Expr("attempts + 1"))
+
+	expected := phoneCodeHash(userID, strings.TrimSpace(code))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(record.CodeHash)) != 1 {
+		return false
+	}
+
+	result := db.Model(&models.PhoneCode{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// SetPhone attaches a phone number to the account and texts a verification code
+func (h *MFAHandler) SetPhone(c *gin.Context) {
+	var input struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := sms.NormalizePhone(input.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.SMSMFAEnabled && phone != user.Phone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Disable SMS two-factor authentication before changing your phone number"})
+		return
+	}
+
+	if user.PhoneVerified && phone == user.Phone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number is already verified"})
+		return
+	}
+
+	// Don't spend a text on a number that can't be verified
+	if phoneInUse(h.db, phone, user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number is already in use"})
+		return
+	}
+
+	if wait := phoneCodeRetryAfter(h.db, user.ID); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another code"})
+		return
+	}
+
+	if err := h.db.Model(&user).Updates(map[string]interface{}{"phone": phone, "phone_verified": false}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save phone number"})
+		return
+	}
+
+	if err := sendPhoneCode(h.db, h.sms, user.ID, phone, models.PhoneCodePurposeVerification); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send verification code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent", "phone": maskPhone(phone)})
+}
+
+// VerifyPhone confirms the phone number with the texted code
+func (h *MFAHandler) VerifyPhone(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Phone == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Add a phone number first"})
+		return
+	}
+
+	// Checked before the code is used up so it isn't wasted
+	if phoneInUse(h.db, user.Phone, user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number is already in use"})
+		return
+	}
+
+	if !verifyPhoneCode(h.db, user.ID, models.PhoneCodePurposeVerification, input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+
+	// The unique index on verified numbers catches an account that
+	// verified the same number since the code was sent
+	if err := h.db.Model(&user).Update("phone_verified", true).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number is already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify phone number"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPhoneVerified, maskPhone(user.Phone))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number verified"})
+}
+
+// RemovePhone detaches the phone number. SMS 2FA must be disabled first.
+func (h *MFAHandler) RemovePhone(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.SMSMFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Disable SMS two-factor authentication first"})
+		return
+	}
+
+	if err := h.db.Model(&user).Updates(map[string]interface{}{"phone": "", "phone_verified": false}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove phone number"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventPhoneRemoved, maskPhone(user.Phone))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number removed"})
+}
+
+// EnableSMSMFA turns on SMS as a second factor. Returns recovery codes if
+// the user didn't have any yet.
+func (h *MFAHandler) EnableSMSMFA(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is only available for accounts with a password"})
+		return
+	}
+
+	if !verifyPassword(h.db, &user, input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if !user.PhoneVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verify a phone number first"})
+		return
+	}
+
+	if user.SMSMFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SMS two-factor authentication is already enabled"})
+		return
+	}
+
+	// First second factor on the account: hand out recovery codes
+	var codes []string
+	if !requiresMFA(user) {
+		var err error
+		codes, err = generateRecoveryCodes(h.db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+	}
+
+	if err := h.db.Model(&user).Update("sms_mfa_enabled", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable SMS two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventMFAEnabled, "sms")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "SMS two-factor authentication enabled",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSMS codes to %s can now be used to sign in to your account.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username, maskPhone(user.Phone),
+		),
+	})
+
+	response := gin.H{"message": "SMS two-factor authentication enabled"}
+	if codes != nil {
+		response["recovery_codes"] = codes
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableSMSMFA turns SMS 2FA off. Requires the password and a second factor.
+func (h *MFAHandler) DisableSMSMFA(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		mfaCodes
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.SMSMFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SMS two-factor authentication is not enabled"})
+		return
+	}
+
+	if !verifyPassword(h.db, &user, input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if !verifySecondFactor(h.db, c, &user, input.mfaCodes) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Recovery codes are still needed while TOTP is on
+		if !user.TOTPEnabled {
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("sms_mfa_enabled", false).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable SMS two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(h.db, c, user.ID, models.SecurityEventMFADisabled, "sms")
+	sendEmail(h.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "SMS two-factor authentication disabled",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSMS codes can no longer be used to sign in to your account.\n\nIf this wasn't you, reset your password immediately.\n",
+			user.Username,
+		),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "SMS two-factor authentication disabled"})
+}
+
+// SendLoginSMS texts a login code during the second step of login. Also
+// used by signed-in users who need a code to change 2FA settings.
+func (h *MFAHandler) SendLoginSMS(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		id, err := parseMFAToken(input.MFAToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return
+		}
+		userID = id
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	if !user.SMSMFAEnabled || !user.PhoneVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SMS two-factor authentication is not enabled"})
+		return
+	}
+
+	if wait := phoneCodeRetryAfter(h.db, user.ID); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another code"})
+		return
+	}
+
+	if err := sendPhoneCode(h.db, h.sms, user.ID, user.Phone, models.PhoneCodePurposeLogin); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code sent", "phone": maskPhone(user.Phone)})
+}
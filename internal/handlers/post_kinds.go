@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/urlnorm"
+)
+
+// Most images a gallery post can have
+const maxGalleryItems = 20
+
+// postMediaInput is one image or video in a create post request, either an
+// upload from POST /api/media or an external URL
+type postMediaInput struct {
+	MediaID int    `json:"media_id"`
+	URL     string `json:"url"`
+	Caption string `json:"caption" binding:"max=180"`
+}
+
+// preloadPostMedia loads a post's media in display order, with the
+// variants of uploaded images, and the link preview of link posts
+func preloadPostMedia(db *gorm.DB) *gorm.DB {
+	return db.Preload("Media", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position asc")
+	}).Preload("Media.Upload.Variants").Preload("Preview.Media.Variants")
+}
+
+// postMediaResponse describes a post's media. Uploads include their
+// dimensions and variant URLs; external URLs only have the URL.
+func postMediaResponse(items []models.PostMedia) []gin.H {
+	response := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		entry := gin.H{
+			"id":         item.ID,
+			"position":   item.Position,
+			"url":        item.URL,
+			"caption":    item.Caption,
+			"created_at": item.CreatedAt,
+		}
+		if item.Upload != nil {
+			entry["media_id"] = item.Upload.ID
+			entry["kind"] = item.Upload.Kind
+			entry["status"] = item.Upload.Status
+			entry["width"] = item.Upload.Width
+			entry["height"] = item.Upload.Height
+			entry["variants"] = mediaVariantURLs(item.Upload.Variants)
+		}
+		response = append(response, entry)
+	}
+	return response
+}
+
+// inferPostKind picks a kind for clients that don't send one yet
+func inferPostKind(kind, rawURL, image string) string {
+	switch {
+	case kind != "":
+		return kind
+	case image != "":
+		return models.PostKindImage
+	case rawURL != "":
+		return models.PostKindLink
+	default:
+		return models.PostKindText
+	}
+}
+
+// applyPostKind validates the kind-specific parts of a new post and sets
+// them on it. Uploaded media must belong to the post's author. The returned
+// error is safe to show to the user.
+func applyPostKind(db *gorm.DB, post *models.Post, kind, rawURL string, media []postMediaInput) error {
+	post.Kind = kind
+
+	switch kind {
+	case models.PostKindText:
+		if rawURL != "" || len(media) > 0 {
+			return errors.New("Text posts can't have a URL or media")
+		}
+
+	case models.PostKindLink:
+		if len(media) > 0 {
+			return errors.New("Link posts can't have media")
+		}
+		normalized, domain, err := urlnorm.Normalize(rawURL)
+		if err != nil {
+			return errors.New("Link posts need a valid http or https URL")
+		}
+		post.URL, post.Domain = normalized, domain
+
+	case models.PostKindImage, models.PostKindVideo, models.PostKindGallery:
+		if rawURL != "" {
+			return fmt.Errorf("Posts of kind %q can't have a URL", kind)
+		}
+		switch {
+		case kind == models.PostKindGallery && (len(media) < 2 || len(media) > maxGalleryItems):
+			return fmt.Errorf("Gallery posts need between 2 and %d images", maxGalleryItems)
+		case kind != models.PostKindGallery && len(media) != 1:
+			return fmt.Errorf("Posts of kind %q need exactly one media item", kind)
+		}
+
+		wantKind := models.MediaKindImage
+		if kind == models.PostKindVideo {
+			wantKind = models.MediaKindVideo
+		}
+
+		post.Media = make([]models.PostMedia, 0, len(media))
+		for i, item := range media {
+			if item.MediaID != 0 {
+				upload, err := findOwnMedia(db, authorIDOf(post.UserID), item.MediaID)
+				if err != nil {
+					return fmt.Errorf("Media item %d was not found", i+1)
+				}
+				if upload.Kind != wantKind {
+					return fmt.Errorf("Media item %d must be of kind %q", i+1, wantKind)
+				}
+				post.Media = append(post.Media, models.PostMedia{Position: i, MediaID: &upload.ID, URL: mediaURL(upload.Key), Caption: item.Caption})
+				continue
+			}
+
+			normalized, _, err := urlnorm.Normalize(item.URL)
+			if err != nil {
+				return fmt.Errorf("Media item %d needs a media_id or a valid http or https URL", i+1)
+			}
+			post.Media = append(post.Media, models.PostMedia{Position: i, URL: normalized, Caption: item.Caption})
+		}
+		if kind != models.PostKindVideo {
+			post.Image = post.Media[0].URL
+		}
+
+	default:
+		return errors.New("kind must be one of text, link, image, video or gallery")
+	}
+	return nil
+}
+
+// linkPreviewResponse describes a link post's preview, or is nil for other
+// posts. The title and image are empty until the preview has been fetched.
+func linkPreviewResponse(preview *models.LinkPreview) gin.H {
+	if preview == nil {
+		return nil
+	}
+	response := gin.H{
+		"status":      preview.Status,
+		"title":       preview.Title,
+		"description": preview.Description,
+		"site_name":   preview.SiteName,
+		"image":       "",
+	}
+	if preview.Media != nil {
+		response["image"] = mediaURL(preview.Media.Key)
+		response["image_variants"] = mediaVariantURLs(preview.Media.Variants)
+	}
+	return response
+}
@@ -1,20 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/emilythestrangee/reddit-clone/backend/internal/jobs"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/markdown"
 	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/unfurl"
 )
 
 type PostHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	storage  storage.Storage
+	unfurler *unfurl.Fetcher
 }
 
-func NewPostHandler(db *gorm.DB) *PostHandler {
-	return &PostHandler{db: db}
+func NewPostHandler(db *gorm.DB, store storage.Storage, unfurler *unfurl.Fetcher) *PostHandler {
+	return &PostHandler{db: db, storage: store, unfurler: unfurler}
 }
 
 func (h *PostHandler) calculateVotes(postID int) (int, int) {
@@ -24,24 +34,74 @@ func (h *PostHandler) calculateVotes(postID int) (int, int) {
 	return int(upvotes), int(downvotes)
 }
 
+// postScore is a post's upvotes minus downvotes
+const postScore = "(SELECT COALESCE(SUM(vote_type), 0) FROM votes WHERE votes.post_id = posts.id)"
+
+// postOrder returns the ORDER BY clause for a feed sort
+func postOrder(sort string) string {
+	switch sort {
+	case models.FeedSortTop:
+		return postScore + " DESC, created_at DESC"
+	case models.FeedSortHot:
+		// Score decays with age, as in Hacker News ranking
+		return postScore + " / POWER(EXTRACT(EPOCH FROM NOW() - created_at) / 3600 + 2, 1.5) DESC, created_at DESC"
+	default:
+		return "created_at DESC"
+	}
+}
+
 func (h *PostHandler) GetPosts(c *gin.Context) {
 	var posts []models.Post
 
-	if err := h.db.Preload("User").Order("created_at desc").Find(&posts).Error; err != nil {
+	sort, err := resolveSort(c, h.db, func(p models.UserPreferences) string { return p.FeedSort },
+		models.FeedSortHot, models.FeedSortNew, models.FeedSortTop)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of hot, new or top"})
+		return
+	}
+
+	query := preloadPostMedia(h.db).Preload("User").Order(postOrder(sort))
+	// Signed-in viewers don't see posts from users they've blocked
+	viewerID, ok := extractUserID(c)
+	if ok {
+		if blocked := blockedUserIDs(h.db, viewerID); len(blocked) > 0 {
+			query = query.Where("user_id IS NULL OR user_id NOT IN ?", blocked)
+		}
+		query = query.Where("id NOT IN (?)", hiddenPostIDs(h.db, viewerID))
+	}
+	// NSFW posts are only shown to signed-in users who opted in
+	prefs := loadPreferences(h.db, viewerID)
+	if !prefs.ShowNSFW {
+		query = excludeNSFW(query)
+	}
+
+	if err := query.Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
 		return
 	}
 
+	postIDs := make([]int, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	saved := savedIDs(h.db, viewerID, "post_id", postIDs)
+	nsfwCommunities := nsfwCommunityIDs(h.db, posts)
+
 	// DON'T embed models.Post — build each response manually
 	var responses []gin.H
 	for _, post := range posts {
 		up, down := h.calculateVotes(post.ID)
-		responses = append(responses, gin.H{
+		response := gin.H{
 			"id":         post.ID,
 			"title":      post.Title,
 			"body":       post.Body,
 			"content":    post.Content,
 			"image":      post.Image,
+			"kind":       post.Kind,
+			"url":        post.URL,
+			"domain":     post.Domain,
+			"media":      postMediaResponse(post.Media),
+			"preview":    linkPreviewResponse(post.Preview),
 			"user_id":    post.UserID,
 			"author_id":  post.AuthorID,
 			"community":  post.Community,
@@ -49,9 +109,16 @@ func (h *PostHandler) GetPosts(c *gin.Context) {
 			"upvotes":    up,
 			"downvotes":  down,
 			"comments":   post.Comments,
+			"saved":      saved[post.ID],
 			"created_at": post.CreatedAt,
 			"updated_at": post.UpdatedAt,
-		})
+			"edited":     post.EditedAt != nil,
+			"edited_at":  post.EditedAt,
+		}
+		for k, v := range contentFlags(post, nsfwCommunities, prefs) {
+			response[k] = v
+		}
+		responses = append(responses, response)
 	}
 
 	// If no posts, return empty array not null
@@ -67,43 +134,81 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 	postID := c.Param("id")
 	var post models.Post
 
-	if err := h.db.Preload("User").First(&post, postID).Error; err != nil {
+	if err := preloadPostMedia(h.db).Preload("User").First(&post, postID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
 		return
 	}
 
 	up, down := h.calculateVotes(post.ID)
+	viewerID, _ := extractUserID(c)
+	recordView(h.db, viewerID, post.ID)
+
+	// Body and content hold the same markdown; older posts may only have one
+	source := post.Content
+	if source == "" {
+		source = post.Body
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"id":         post.ID,
 		"title":      post.Title,
 		"body":       post.Body,
 		"content":    post.Content,
+		"body_html":  markdown.Render(source),
 		"image":      post.Image,
+		"kind":       post.Kind,
+		"url":        post.URL,
+		"domain":     post.Domain,
+		"media":      postMediaResponse(post.Media),
+		"preview":    linkPreviewResponse(post.Preview),
 		"user_id":    post.UserID,
 		"author_id":  post.AuthorID,
 		"user":       post.User,
 		"upvotes":    up,
 		"downvotes":  down,
+		"saved":      savedIDs(h.db, viewerID, "post_id", []int{post.ID})[post.ID],
 		"created_at": post.CreatedAt,
 		"updated_at": post.UpdatedAt,
-	})
+		"edited":     post.EditedAt != nil,
+		"edited_at":  post.EditedAt,
+	}
+	for k, v := range contentFlags(post, nsfwCommunityIDs(h.db, []models.Post{post}), loadPreferences(h.db, viewerID)) {
+		response[k] = v
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // CreatePost creates a new post (PROTECTED - requires authentication)
 func (h *PostHandler) CreatePost(c *gin.Context) {
 	var input struct {
-		Title   string `json:"title" binding:"required"`
-		Body    string `json:"body"`
-		Content string `json:"content"`
-		Image   string `json:"image"`
+		Title   string           `json:"title" binding:"required"`
+		Body    string           `json:"body"`
+		Content string           `json:"content"`
+		Image   string           `json:"image"` // Deprecated: use kind "image" with media
+		Kind    string           `json:"kind"`
+		URL     string           `json:"url"`
+		Media   []postMediaInput `json:"media" binding:"dive"`
+
+		Community string `json:"community"` // Name of an existing community
+		NSFW      bool   `json:"nsfw"`
+		Spoiler   bool   `json:"spoiler"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+		if input.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	// Older clients send a single image URL
+	if input.Image != "" && len(input.Media) == 0 {
+		input.Media = []postMediaInput{{URL: input.Image}}
+	}
+
 	// Get user ID from auth middleware
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -135,18 +240,44 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		Title:    input.Title,
 		Body:     postContent,
 		Content:  postContent,
-		Image:    input.Image,
-		AuthorID: authorID,
-		UserID:   authorID,
+		AuthorID: &authorID,
+		UserID:   &authorID,
+		NSFW:     input.NSFW,
+		Spoiler:  input.Spoiler,
+	}
+
+	if input.Community != "" {
+		var community models.Community
+		if err := h.db.Where("name = ?", input.Community).First(&community).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Community not found"})
+			return
+		}
+		post.CommunityID = community.ID
+		post.Community = community.Name
+	}
+
+	if err := applyPostKind(h.db, &post, inferPostKind(input.Kind, input.URL, input.Image), input.URL, input.Media); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Link previews are fetched in the background
+	if post.Kind == models.PostKindLink {
+		post.Preview = &models.LinkPreview{Status: models.LinkPreviewPending}
 	}
 
+	// Creates the media and preview rows in the same transaction
 	if err := h.db.Create(&post).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
 
+	if post.Preview != nil {
+		go jobs.UnfurlLink(context.Background(), h.db, h.storage, h.unfurler, post.ID)
+	}
+
 	// Reload with user information
-	h.db.Preload("User").First(&post, post.ID)
+	preloadPostMedia(h.db).Preload("User").First(&post, post.ID)
 
 	c.JSON(http.StatusCreated, post)
 }
@@ -195,11 +326,24 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 	}
 
 	// Check ownership
-	if post.AuthorID != currentUserID && post.UserID != currentUserID {
+	if !post.IsAuthor(currentUserID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own posts"})
 		return
 	}
 
+	// Titles can only be fixed shortly after posting
+	if input.Title != "" && input.Title != post.Title {
+		if window := titleEditWindow(); time.Since(post.CreatedAt) > window {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Titles can only be edited in the first %d minutes after posting", int(window.Minutes()))})
+			return
+		}
+	}
+
+	original := models.Revision{PostID: &post.ID, EditorID: authorIDOf(post.AuthorID), Title: post.Title, Body: post.Content, CreatedAt: post.CreatedAt}
+	if original.Body == "" {
+		original.Body = post.Body
+	}
+
 	// Update fields
 	if input.Title != "" {
 		post.Title = input.Title
@@ -213,8 +357,23 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		post.Body = input.Content
 	}
 
-	h.db.Save(&post)
-	h.db.Preload("User").First(&post, post.ID)
+	if post.Title != original.Title || post.Content != original.Body {
+		now := time.Now()
+		post.EditedAt = &now
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&post).Error; err != nil {
+				return err
+			}
+			return recordRevision(tx, "post_id", post.ID, original, models.Revision{
+				PostID: &post.ID, EditorID: currentUserID, Title: post.Title, Body: post.Content, CreatedAt: now,
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+			return
+		}
+	}
+	preloadPostMedia(h.db).Preload("User").First(&post, post.ID)
 
 	c.JSON(http.StatusOK, post)
 }
@@ -252,15 +411,57 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 	}
 
 	// Check ownership
-	if post.AuthorID != currentUserID && post.UserID != currentUserID {
+	if !post.IsAuthor(currentUserID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own posts"})
 		return
 	}
 
-	if err := h.db.Delete(&post).Error; err != nil {
+	// Uploads and the preview thumbnail are deleted after the commit once
+	// nothing else uses them
+	var mediaIDs []int
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var previewIDs []int
+		if err := tx.Model(&models.PostMedia{}).Where("post_id = ? AND media_id IS NOT NULL", post.ID).Pluck("media_id", &mediaIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LinkPreview{}).Where("post_id = ? AND media_id IS NOT NULL", post.ID).Pluck("media_id", &previewIDs).Error; err != nil {
+			return err
+		}
+		mediaIDs = append(mediaIDs, previewIDs...)
+
+		// Comments and votes reference the post without ON DELETE CASCADE,
+		// so they and everything pointing at them go first
+		const postComments = "comment_id IN (SELECT id FROM comments WHERE post_id = ?)"
+		for _, d := range []struct {
+			where string
+			model interface{}
+		}{
+			{postComments, &models.Vote{}},
+			{postComments, &models.Revision{}},
+			{postComments, &models.SavedItem{}},
+			{"post_id = ?", &models.Comment{}},
+			{"post_id = ?", &models.Vote{}},
+			{"post_id = ?", &models.PostMedia{}},
+			{"post_id = ?", &models.LinkPreview{}},
+			{"post_id = ?", &models.Revision{}},
+			{"post_id = ?", &models.SavedItem{}},
+			{"post_id = ?", &models.HiddenPost{}},
+			{"post_id = ?", &models.PostView{}},
+		} {
+			if err := tx.Where(d.where, post.ID).Delete(d.model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&post).Error
+	})
+	if err != nil {
+		log.Printf("Failed to delete post %d: %v", post.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
 		return
 	}
+	if err := jobs.DeleteUnusedMedia(c.Request.Context(), h.db, h.storage, mediaIDs); err != nil {
+		log.Printf("Failed to delete media of post %d: %v", post.ID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
 }
@@ -346,7 +547,7 @@ func (h *PostHandler) GetUserPosts(c *gin.Context) {
 	userID := c.Param("id")
 	var posts []models.Post
 
-	if err := h.db.Preload("User").Where("user_id = ? OR author_id = ?", userID, userID).Order("created_at desc").Find(&posts).Error; err != nil {
+	if err := preloadPostMedia(h.db).Preload("User").Where("user_id = ? OR author_id = ?", userID, userID).Order("created_at desc").Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user posts"})
 		return
 	}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+)
+
+func TestDeletePostWithCommentsAndVotes(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+
+	// The production schema references posts without ON DELETE CASCADE
+	for _, stmt := range []string{
+		"ALTER TABLE comments ADD FOREIGN KEY (post_id) REFERENCES posts(id)",
+		"ALTER TABLE votes ADD FOREIGN KEY (post_id) REFERENCES posts(id)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewPostHandler(db, store, nil)
+
+	author := models.User{Username: "author", Email: "author@example.com"}
+	voter := models.User{Username: "voter", Email: "voter@example.com"}
+	for _, u := range []*models.User{&author, &voter} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := models.Post{Title: "Post", UserID: &author.ID, AuthorID: &author.ID}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	comment := models.Comment{Body: "Comment", AuthorID: &voter.ID, PostID: post.ID}
+	if err := db.Create(&comment).Error; err != nil {
+		t.Fatal(err)
+	}
+	rows := []interface{}{
+		&models.Comment{Body: "Reply", AuthorID: &author.ID, PostID: post.ID, ParentCommentID: &comment.ID},
+		&models.Vote{UserID: voter.ID, PostID: post.ID, VoteType: 1},
+		&models.SavedItem{UserID: voter.ID, PostID: post.ID},
+		&models.SavedItem{UserID: author.ID, CommentID: comment.ID},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/posts/"+strconv.Itoa(post.ID), nil)
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(post.ID)}}
+	c.Set("user_id", author.ID)
+
+	h.DeletePost(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	for _, model := range []interface{}{&models.Post{}, &models.Comment{}, &models.Vote{}, &models.SavedItem{}} {
+		var count int64
+		db.Model(model).Count(&count)
+		if count != 0 {
+			t.Errorf("%T: %d rows left after deleting the post", model, count)
+		}
+	}
+}
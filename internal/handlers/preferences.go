@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	_ "time/tzdata" // Validate timezones on hosts without a zoneinfo database
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// loadPreferences returns a user's saved preferences, or the defaults for
+// anonymous viewers and users who never changed anything
+func loadPreferences(db *gorm.DB, userID int) models.UserPreferences {
+	prefs := models.DefaultUserPreferences(userID)
+	if userID != 0 {
+		db.Where("user_id = ?", userID).Limit(1).Find(&prefs)
+	}
+	return prefs
+}
+
+// GetPreferences returns the current user's settings
+func (h *AccountHandler) GetPreferences(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadPreferences(h.db, userID))
+}
+
+// UpdatePreferences changes the settings present in the request body and
+// leaves the rest as they are
+func (h *AccountHandler) UpdatePreferences(c *gin.Context) {
+	var input struct {
+		FeedSort           *string `json:"feed_sort" binding:"omitempty,oneof=hot new top"`
+		CommentSort        *string `json:"comment_sort" binding:"omitempty,oneof=top new old"`
+		ShowNSFW           *bool   `json:"show_nsfw"`
+		BlurSpoilers       *bool   `json:"blur_spoilers"`
+		EmailNotifications *struct {
+			PostReplies    *bool `json:"post_replies"`
+			CommentReplies *bool `json:"comment_replies"`
+			Mentions       *bool `json:"mentions"`
+			NewFollowers   *bool `json:"new_followers"`
+		} `json:"email_notifications"`
+		PrivateProfile *bool   `json:"private_profile"`
+		HideFollowers  *bool   `json:"hide_followers"`
+		HideFollowing  *bool   `json:"hide_following"`
+		HideVotes      *bool   `json:"hide_votes"`
+		HideFromSearch *bool   `json:"hide_from_search"`
+		PauseHistory   *bool   `json:"pause_history"`
+		Language       *string `json:"language" binding:"omitempty,max=35"`
+		Timezone       *string `json:"timezone" binding:"omitempty,max=64"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	prefs := loadPreferences(h.db, userID)
+
+	if input.Language != nil {
+		tag, err := language.Parse(*input.Language)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "language must be a BCP 47 tag such as \"en\" or \"pt-BR\""})
+			return
+		}
+		prefs.Language = tag.String()
+	}
+	if input.Timezone != nil {
+		// LoadLocation also accepts "" and "Local", which mean the server's zone
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" || *input.Timezone == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timezone must be an IANA time zone such as \"Europe/London\""})
+			return
+		}
+		prefs.Timezone = *input.Timezone
+	}
+
+	setIfPresent(&prefs.FeedSort, input.FeedSort)
+	setIfPresent(&prefs.CommentSort, input.CommentSort)
+	setIfPresent(&prefs.ShowNSFW, input.ShowNSFW)
+	setIfPresent(&prefs.BlurSpoilers, input.BlurSpoilers)
+	setIfPresent(&prefs.PrivateProfile, input.PrivateProfile)
+	setIfPresent(&prefs.HideFollowers, input.HideFollowers)
+	setIfPresent(&prefs.HideFollowing, input.HideFollowing)
+	setIfPresent(&prefs.HideVotes, input.HideVotes)
+	setIfPresent(&prefs.HideFromSearch, input.HideFromSearch)
+	setIfPresent(&prefs.PauseHistory, input.PauseHistory)
+	if n := input.EmailNotifications; n != nil {
+		setIfPresent(&prefs.EmailNotifications.PostReplies, n.PostReplies)
+		setIfPresent(&prefs.EmailNotifications.CommentReplies, n.CommentReplies)
+		setIfPresent(&prefs.EmailNotifications.Mentions, n.Mentions)
+		setIfPresent(&prefs.EmailNotifications.NewFollowers, n.NewFollowers)
+	}
+
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&prefs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// setIfPresent copies an optional PATCH field onto its destination
+func setIfPresent[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// errInvalidSort is returned for sort orders an endpoint doesn't support
+var errInvalidSort = errors.New("invalid sort order")
+
+// resolveSort returns the requested sort order, or the viewer's saved
+// default when the query doesn't specify one
+func resolveSort(c *gin.Context, db *gorm.DB, fallback func(models.UserPreferences) string, allowed ...string) (string, error) {
+	sort := c.Query("sort")
+	if sort == "" {
+		viewerID, _ := extractUserID(c)
+		sort = fallback(loadPreferences(db, viewerID))
+	}
+	for _, s := range allowed {
+		if s == sort {
+			return sort, nil
+		}
+	}
+	return "", errInvalidSort
+}
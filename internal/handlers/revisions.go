@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// titleEditWindow is how long after posting a title can still be fixed,
+// set with POST_TITLE_EDIT_MINUTES
+func titleEditWindow() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("POST_TITLE_EDIT_MINUTES")); err == nil && minutes >= 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 5 * time.Minute
+}
+
+// authorIDOf returns the author of a post or comment, or 0 if their
+// account was purged
+func authorIDOf(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// isModerator reports whether a user can see everyone's edit history
+func isModerator(db *gorm.DB, userID int) bool {
+	var count int64
+	db.Model(&models.User{}).Where("id = ? AND role IN ?", userID, []string{models.RoleModerator, models.RoleAdmin}).Count(&count)
+	return count > 0
+}
+
+// recordRevision stores a new version of a post or comment. The original
+// is stored first if this is the first edit, so the history is complete.
+func recordRevision(tx *gorm.DB, scope string, id int, original, revision models.Revision) error {
+	var count int64
+	if err := tx.Model(&models.Revision{}).Where(scope+" = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := tx.Create(&original).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&revision).Error
+}
+
+// revisionsResponse lists revisions oldest first, each with a unified diff
+// of its body against the one before
+func revisionsResponse(revisions []models.Revision) []gin.H {
+	response := make([]gin.H, 0, len(revisions))
+	for i, rev := range revisions {
+		entry := gin.H{
+			"number":     i + 1,
+			"title":      rev.Title,
+			"body":       rev.Body,
+			"editor_id":  rev.EditorID,
+			"created_at": rev.CreatedAt,
+			"diff":       "",
+		}
+		if i > 0 {
+			prev := revisions[i-1]
+			entry["title_changed"] = rev.Title != prev.Title
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(prev.Body),
+				B:        difflib.SplitLines(rev.Body),
+				FromFile: fmt.Sprintf("revision %d", i),
+				ToFile:   fmt.Sprintf("revision %d", i+1),
+				Context:  3,
+			})
+			entry["diff"] = diff
+		}
+		response = append(response, entry)
+	}
+	return response
+}
+
+// GetPostRevisions returns a post's edit history. Only the author and
+// moderators can see it.
+func (h *PostHandler) GetPostRevisions(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var post models.Post
+	if err := h.db.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if !post.IsAuthor(userID) && !isModerator(h.db, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author and moderators can see the edit history"})
+		return
+	}
+
+	var revisions []models.Revision
+	if err := h.db.Where("post_id = ?", post.ID).Order("created_at asc, id asc").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	// Posts that were never edited only have their current version
+	if len(revisions) == 0 {
+		revisions = append(revisions, models.Revision{PostID: &post.ID, EditorID: authorIDOf(post.AuthorID), Title: post.Title, Body: post.Content, CreatedAt: post.CreatedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"post_id":   post.ID,
+		"edited_at": post.EditedAt,
+		"revisions": revisionsResponse(revisions),
+	})
+}
+
+// GetCommentRevisions returns a comment's edit history. Only the author
+// and moderators can see it.
+func (h *CommentHandler) GetCommentRevisions(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var comment models.Comment
+	if err := h.db.First(&comment, c.Param("commentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if !comment.IsAuthor(userID) && !isModerator(h.db, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author and moderators can see the edit history"})
+		return
+	}
+
+	var revisions []models.Revision
+	if err := h.db.Where("comment_id = ?", comment.ID).Order("created_at asc, id asc").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, models.Revision{CommentID: &comment.ID, EditorID: authorIDOf(comment.AuthorID), Body: comment.Body, CreatedAt: comment.CreatedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment_id": comment.ID,
+		"edited_at":  comment.EditedAt,
+		"revisions":  revisionsResponse(revisions),
+	})
+}
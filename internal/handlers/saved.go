@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/markdown"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+type SavedHandler struct {
+	db *gorm.DB
+}
+
+func NewSavedHandler(db *gorm.DB) *SavedHandler {
+	return &SavedHandler{db: db}
+}
+
+// savedIDs returns which of the given posts or comments a user has saved.
+// column is "post_id" or "comment_id".
+func savedIDs(db *gorm.DB, userID int, column string, ids []int) map[int]bool {
+	saved := map[int]bool{}
+	if userID == 0 || len(ids) == 0 {
+		return saved
+	}
+	var found []int
+	db.Model(&models.SavedItem{}).Where("user_id = ? AND "+column+" IN ?", userID, ids).Pluck(column, &found)
+	for _, id := range found {
+		saved[id] = true
+	}
+	return saved
+}
+
+// findCollection loads a collection belonging to the user
+func findCollection(db *gorm.DB, userID int, collectionID string) (*models.SavedCollection, error) {
+	var collection models.SavedCollection
+	if err := db.Where("id = ? AND user_id = ?", collectionID, userID).First(&collection).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// SavePost saves a post, optionally into one of the user's collections
+func (h *SavedHandler) SavePost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || h.db.First(&models.Post{}, postID).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	h.save(c, models.SavedItem{PostID: postID})
+}
+
+// SaveComment saves a comment, optionally into one of the user's collections
+func (h *SavedHandler) SaveComment(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil || h.db.First(&models.Comment{}, commentID).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	h.save(c, models.SavedItem{CommentID: commentID})
+}
+
+// save stores a saved item. Saving an item again with a collection_id
+// moves it to that collection; without one it stays where it is.
+func (h *SavedHandler) save(c *gin.Context, item models.SavedItem) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		CollectionID *int `json:"collection_id"`
+	}
+	// The body is optional
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item.UserID = userID
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}, {Name: "comment_id"}},
+		DoNothing: true,
+	}
+	if input.CollectionID != nil {
+		if _, err := findCollection(h.db, userID, strconv.Itoa(*input.CollectionID)); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return
+		}
+		item.CollectionID = input.CollectionID
+		onConflict = clause.OnConflict{
+			Columns:   onConflict.Columns,
+			DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+		}
+	}
+
+	if err := h.db.Clauses(onConflict).Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save"})
+		return
+	}
+	h.db.Where("user_id = ? AND post_id = ? AND comment_id = ?", userID, item.PostID, item.CommentID).First(&item)
+
+	c.JSON(http.StatusOK, gin.H{"saved": true, "collection_id": item.CollectionID, "saved_at": item.CreatedAt})
+}
+
+// UnsavePost removes a post from the user's saved items
+func (h *SavedHandler) UnsavePost(c *gin.Context) {
+	postID, _ := strconv.Atoi(c.Param("id"))
+	h.unsave(c, "post_id", postID)
+}
+
+// UnsaveComment removes a comment from the user's saved items
+func (h *SavedHandler) UnsaveComment(c *gin.Context) {
+	commentID, _ := strconv.Atoi(c.Param("commentId"))
+	h.unsave(c, "comment_id", commentID)
+}
+
+func (h *SavedHandler) unsave(c *gin.Context, column string, id int) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.db.Where("user_id = ? AND "+column+" = ?", userID, id).Delete(&models.SavedItem{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsave"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved": false})
+}
+
+// GetSaved lists the user's saved items, newest first. It can be filtered
+// with type=post or type=comment and with collection_id.
+func (h *SavedHandler) GetSaved(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, limit, ok := parsePage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page and limit must be positive numbers"})
+		return
+	}
+
+	// Skip saves of posts and comments that have since been deleted, so
+	// the total matches what is listed
+	query := h.db.Model(&models.SavedItem{}).
+		Where("user_id = ?", userID).
+		Where("(post_id = 0 OR EXISTS (SELECT 1 FROM posts WHERE posts.id = saved_items.post_id))").
+		Where("(comment_id = 0 OR EXISTS (SELECT 1 FROM comments WHERE comments.id = saved_items.comment_id))")
+	switch c.Query("type") {
+	case "":
+	case models.SavedTypePost:
+		query = query.Where("post_id <> 0")
+	case models.SavedTypeComment:
+		query = query.Where("comment_id <> 0")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be post or comment"})
+		return
+	}
+	if collectionID := c.Query("collection_id"); collectionID != "" {
+		if _, err := findCollection(h.db, userID, collectionID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return
+		}
+		query = query.Where("collection_id = ?", collectionID)
+	}
+
+	var total int64
+	var items []models.SavedItem
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch saved items"})
+		return
+	}
+	if err := query.Order("created_at desc, id desc").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch saved items"})
+		return
+	}
+
+	var postIDs, commentIDs []int
+	for _, item := range items {
+		if item.PostID != 0 {
+			postIDs = append(postIDs, item.PostID)
+		} else {
+			commentIDs = append(commentIDs, item.CommentID)
+		}
+	}
+
+	posts := map[int]models.Post{}
+	var found []models.Post
+	if len(postIDs) > 0 {
+		preloadPostMedia(h.db).Preload("User").Where("id IN ?", postIDs).Find(&found)
+		for _, p := range found {
+			posts[p.ID] = p
+		}
+	}
+	nsfwCommunities := nsfwCommunityIDs(h.db, found)
+	prefs := loadPreferences(h.db, userID)
+
+	comments := map[int]models.Comment{}
+	postTitles := map[int]string{}
+	if len(commentIDs) > 0 {
+		var foundComments []models.Comment
+		h.db.Preload("User").Where("id IN ?", commentIDs).Find(&foundComments)
+		var parentIDs []int
+		for _, cm := range foundComments {
+			comments[cm.ID] = cm
+			parentIDs = append(parentIDs, cm.PostID)
+		}
+		var parents []models.Post
+		h.db.Select("id", "title").Where("id IN ?", parentIDs).Find(&parents)
+		for _, p := range parents {
+			postTitles[p.ID] = p.Title
+		}
+	}
+
+	responses := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		entry := gin.H{
+			"id":            item.ID,
+			"collection_id": item.CollectionID,
+			"saved_at":      item.CreatedAt,
+		}
+		if post, ok := posts[item.PostID]; ok {
+			entry["type"] = models.SavedTypePost
+			response := gin.H{
+				"id":         post.ID,
+				"title":      post.Title,
+				"kind":       post.Kind,
+				"url":        post.URL,
+				"domain":     post.Domain,
+				"image":      post.Image,
+				"media":      postMediaResponse(post.Media),
+				"community":  post.Community,
+				"user":       post.User,
+				"created_at": post.CreatedAt,
+				"edited_at":  post.EditedAt,
+			}
+			for k, v := range contentFlags(post, nsfwCommunities, prefs) {
+				response[k] = v
+			}
+			entry["post"] = response
+		} else if comment, ok := comments[item.CommentID]; ok {
+			entry["type"] = models.SavedTypeComment
+			entry["comment"] = gin.H{
+				"id":         comment.ID,
+				"body":       comment.Body,
+				"body_html":  markdown.Render(comment.Body),
+				"post_id":    comment.PostID,
+				"post_title": postTitles[comment.PostID],
+				"user":       comment.User,
+				"created_at": comment.CreatedAt,
+				"edited_at":  comment.EditedAt,
+			}
+		} else {
+			continue
+		}
+		responses = append(responses, entry)
+	}
+
+	c.JSON(http.StatusOK, pageResponse(responses, page, limit, total))
+}
+
+// GetCollections lists the user's collections with how many items each holds
+func (h *SavedHandler) GetCollections(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var collections []models.SavedCollection
+	if err := h.db.Where("user_id = ?", userID).Order("name asc").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collections"})
+		return
+	}
+
+	var counts []struct {
+		CollectionID int
+		Count        int64
+	}
+	h.db.Model(&models.SavedItem{}).
+		Select("collection_id, COUNT(*) AS count").
+		Where("user_id = ? AND collection_id IS NOT NULL", userID).
+		Group("collection_id").
+		Scan(&counts)
+	countByID := map[int]int64{}
+	for _, row := range counts {
+		countByID[row.CollectionID] = row.Count
+	}
+
+	responses := make([]gin.H, 0, len(collections))
+	for _, collection := range collections {
+		responses = append(responses, collectionResponse(collection, countByID[collection.ID]))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func collectionResponse(collection models.SavedCollection, itemCount int64) gin.H {
+	return gin.H{
+		"id":         collection.ID,
+		"name":       collection.Name,
+		"item_count": itemCount,
+		"created_at": collection.CreatedAt,
+		"updated_at": collection.UpdatedAt,
+	}
+}
+
+// collectionNameTaken reports whether the user has another collection
+// with the name
+func collectionNameTaken(db *gorm.DB, userID, exceptID int, name string) bool {
+	var count int64
+	db.Model(&models.SavedCollection{}).Where("user_id = ? AND name = ? AND id <> ?", userID, name, exceptID).Count(&count)
+	return count > 0
+}
+
+// CreateCollection creates an empty collection
+func (h *SavedHandler) CreateCollection(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A name of up to 100 characters is required"})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+
+	if collectionNameTaken(h.db, userID, 0, name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a collection with that name"})
+		return
+	}
+
+	collection := models.SavedCollection{UserID: userID, Name: name}
+	if err := h.db.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, collectionResponse(collection, 0))
+}
+
+// RenameCollection changes a collection's name
+func (h *SavedHandler) RenameCollection(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A name of up to 100 characters is required"})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+
+	collection, err := findCollection(h.db, userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if collectionNameTaken(h.db, userID, collection.ID, name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a collection with that name"})
+		return
+	}
+
+	collection.Name = name
+	if err := h.db.Save(collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename collection"})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.SavedItem{}).Where("collection_id = ?", collection.ID).Count(&count)
+	c.JSON(http.StatusOK, collectionResponse(*collection, count))
+}
+
+// DeleteCollection deletes a collection. Its items stay saved.
+func (h *SavedHandler) DeleteCollection(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	collection, err := findCollection(h.db, userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SavedItem{}).Where("collection_id = ?", collection.ID).Update("collection_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted"})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// recordSecurityEvent appends an entry to the user's security history.
+// Failures are logged rather than failing the request.
+func recordSecurityEvent(db *gorm.DB, c *gin.Context, userID int, event, details string) {
+	entry := models.SecurityEvent{
+		UserID:    userID,
+		Event:     event,
+		Details:   details,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record security event %s for user %d: %v", event, userID, err)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const sessionTTL = 72 * time.Hour
+
+// issueSession records a new session for the user and returns a signed access token for it
+func issueSession(db *gorm.DB, c *gin.Context, user models.User) (string, error) {
+	tokenID, _, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(sessionTTL)
+	session := models.Session{
+		UserID:    user.ID,
+		TokenID:   tokenID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"email":    user.Email,
+		"sid":      tokenID,
+		"exp":      expiresAt.Unix(),
+	})
+
+	return token.SignedString(jwtSecret)
+}
+
+// revokeUserSessions revokes every active session of a user, except the
+// one identified by keepTokenID (pass "" to revoke all)
+func revokeUserSessions(db *gorm.DB, userID int, keepTokenID string) error {
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepTokenID != "" {
+		query = query.Where("token_id <> ?", keepTokenID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
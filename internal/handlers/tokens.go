@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// generateToken returns a random URL-safe token and its hash
+func generateToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	return raw, hashToken(raw), nil
+}
+
+// hashToken hashes a token for storage and lookup
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// createUserToken stores a new single-use token and returns the raw value to email
+func createUserToken(db *gorm.DB, userID int, purpose, email string, ttl time.Duration) (string, error) {
+	raw, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// findUserToken returns an unused, unexpired token with one of the given
+// purposes without redeeming it
+func findUserToken(db *gorm.DB, raw string, purposes ...string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := db.Where("token_hash = ? AND purpose IN ? AND used_at IS NULL AND expires_at > ?", hashToken(raw), purposes, time.Now()).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// consumeUserToken marks a token with one of the given purposes as used and
+// returns it. The update is conditional so the same token can never be
+// redeemed twice.
+func consumeUserToken(db *gorm.DB, raw string, purposes ...string) (*models.UserToken, error) {
+	now := time.Now()
+	result := db.Model(&models.UserToken{}).
+		Where("token_hash = ? AND purpose IN ? AND used_at IS NULL AND expires_at > ?", hashToken(raw), purposes, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidToken
+	}
+
+	var token models.UserToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
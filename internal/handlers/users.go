@@ -3,6 +3,8 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -18,7 +20,15 @@ func NewUserHandler(db *gorm.DB) *UserHandler {
 	return &UserHandler{db: db}
 }
 
-// GetUserProfile returns a user's profile
+// profileVisibility loads a profile owner's privacy settings and reports
+// whether the viewer is the owner, who always sees everything
+func (h *UserHandler) profileVisibility(c *gin.Context, ownerID int) (models.UserPreferences, bool) {
+	viewerID, _ := extractUserID(c)
+	return loadPreferences(h.db, ownerID), viewerID == ownerID
+}
+
+// GetUserProfile returns a user's profile. The email address is only shown
+// to the owner, and private profiles only show the username and avatar.
 func (h *UserHandler) GetUserProfile(c *gin.Context) {
 	userID := c.Param("id")
 	var user models.User
@@ -28,14 +38,18 @@ func (h *UserHandler) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	// Get user's posts
-	var posts []models.Post
-	h.db.Where("user_id = ?", userID).Preload("User").Order("created_at desc").Find(&posts)
+	prefs, isOwner := h.profileVisibility(c, user.ID)
 
-	// Get follower/following counts
-	var followerCount, followingCount int64
-	h.db.Model(&models.Follow{}).Where("following_id = ?", userID).Count(&followerCount)
-	h.db.Model(&models.Follow{}).Where("follower_id = ?", userID).Count(&followingCount)
+	profile := gin.H{
+		"id":              user.ID,
+		"username":        user.Username,
+		"avatar":          user.Avatar,
+		"avatar_variants": avatarVariantURLs(h.db, user),
+		"private":         prefs.PrivateProfile,
+	}
+	if isOwner {
+		profile["email"] = user.Email
+	}
 
 	// Check if current user follows this user
 	isFollowing := false
@@ -45,18 +59,38 @@ func (h *UserHandler) GetUserProfile(c *gin.Context) {
 		isFollowing = err == nil
 	}
 
+	if prefs.PrivateProfile && !isOwner {
+		c.JSON(http.StatusOK, gin.H{
+			"user":         profile,
+			"posts":        []models.Post{},
+			"is_following": isFollowing,
+		})
+		return
+	}
+	profile["bio"] = user.Bio
+
+	// Get user's posts
+	var posts []models.Post
+	postsQuery := preloadPostMedia(h.db).Where("user_id = ?", userID)
+	if viewerID, _ := extractUserID(c); !isOwner && !loadPreferences(h.db, viewerID).ShowNSFW {
+		postsQuery = excludeNSFW(postsQuery)
+	}
+	postsQuery.Preload("User").Order("created_at desc").Find(&posts)
+
+	// Get follower/following counts
+	var followerCount, followingCount int64
+	h.db.Model(&models.Follow{}).Where("following_id = ?", userID).Count(&followerCount)
+	h.db.Model(&models.Follow{}).Where("follower_id = ?", userID).Count(&followingCount)
+
 	c.JSON(http.StatusOK, gin.H{
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"bio":      user.Bio,
-			"avatar":   user.Avatar,
-		},
-		"posts":           posts,
-		"follower_count":  followerCount,
-		"following_count": followingCount,
-		"is_following":    isFollowing,
+		"user":             profile,
+		"posts":            posts,
+		"follower_count":   followerCount,
+		"following_count":  followingCount,
+		"followers_hidden": prefs.HideFollowers && !isOwner,
+		"following_hidden": prefs.HideFollowing && !isOwner,
+		"votes_hidden":     prefs.HideVotes && !isOwner,
+		"is_following":     isFollowing,
 	})
 }
 
@@ -77,8 +111,9 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 	}
 
 	var input struct {
-		Bio    string `json:"bio"`
-		Avatar string `json:"avatar"`
+		Bio           string `json:"bio"`
+		Avatar        string `json:"avatar"`
+		AvatarMediaID int    `json:"avatar_media_id"` // An image from POST /api/media
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -99,6 +134,16 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 	}
 	if input.Avatar != "" {
 		user.Avatar = input.Avatar
+		user.AvatarMediaID = nil
+	}
+	if input.AvatarMediaID != 0 {
+		media, err := findOwnMedia(h.db, user.ID, input.AvatarMediaID)
+		if err != nil || media.Kind != models.MediaKindImage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar must be an image you uploaded"})
+			return
+		}
+		user.Avatar = mediaURL(media.Key)
+		user.AvatarMediaID = &media.ID
 	}
 
 	// Save to database
@@ -108,18 +153,23 @@ func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"bio":      user.Bio,
-		"avatar":   user.Avatar,
+		"id":              user.ID,
+		"username":        user.Username,
+		"email":           user.Email,
+		"bio":             user.Bio,
+		"avatar":          user.Avatar,
+		"avatar_variants": avatarVariantURLs(h.db, user),
 	})
 }
 
 // FollowUser follows a user
 func (h *UserHandler) FollowUser(c *gin.Context) {
 	followingID := c.Param("id")
-	followerID, _ := c.Get("user_id")
+	followerID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
 	// Can't follow yourself
 	var followingUser models.User
@@ -128,11 +178,16 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 		return
 	}
 
-	if followingUser.ID == followerID.(int) {
+	if followingUser.ID == followerID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
 		return
 	}
 
+	if blockedEitherWay(h.db, followerID, followingUser.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't follow this user"})
+		return
+	}
+
 	// Check if already following
 	var existingFollow models.Follow
 	err := h.db.Where("follower_id = ? AND following_id = ?", followerID, followingID).First(&existingFollow).Error
@@ -142,7 +197,7 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 	}
 
 	follow := models.Follow{
-		FollowerID:  followerID.(int),
+		FollowerID:  followerID,
 		FollowingID: followingUser.ID,
 	}
 
@@ -167,9 +222,19 @@ func (h *UserHandler) UnfollowUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully unfollowed user"})
 }
 
-// GetFollowers returns a user's followers
+// GetFollowers returns a user's followers unless they've hidden the list
 func (h *UserHandler) GetFollowers(c *gin.Context) {
-	userID := c.Param("id")
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if prefs, isOwner := h.profileVisibility(c, userID); !isOwner && (prefs.PrivateProfile || prefs.HideFollowers) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's followers are private"})
+		return
+	}
+
 	var follows []models.Follow
 
 	h.db.Where("following_id = ?", userID).Preload("Follower").Find(&follows)
@@ -186,9 +251,19 @@ func (h *UserHandler) GetFollowers(c *gin.Context) {
 	c.JSON(http.StatusOK, followers)
 }
 
-// GetFollowing returns users that a user is following
+// GetFollowing returns users that a user is following unless they've hidden the list
 func (h *UserHandler) GetFollowing(c *gin.Context) {
-	userID := c.Param("id")
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if prefs, isOwner := h.profileVisibility(c, userID); !isOwner && (prefs.PrivateProfile || prefs.HideFollowing) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The accounts this user follows are private"})
+		return
+	}
+
 	var follows []models.Follow
 
 	h.db.Where("follower_id = ?", userID).Preload("Following").Find(&follows)
@@ -204,3 +279,134 @@ func (h *UserHandler) GetFollowing(c *gin.Context) {
 
 	c.JSON(http.StatusOK, following)
 }
+
+// GetUserVotes lists the posts a user voted on, most recent first, unless
+// they've hidden their voting activity
+func (h *UserHandler) GetUserVotes(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	prefs, isOwner := h.profileVisibility(c, userID)
+	if !isOwner && (prefs.PrivateProfile || prefs.HideVotes) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's votes are private"})
+		return
+	}
+
+	page, limit, ok := parsePage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page and limit must be positive numbers"})
+		return
+	}
+
+	viewerID, _ := extractUserID(c)
+	viewerPrefs := loadPreferences(h.db, viewerID)
+
+	// Votes on deleted posts aren't listed or counted
+	query := h.db.Model(&models.Vote{}).
+		Joins("JOIN posts ON posts.id = votes.post_id").
+		Where("votes.user_id = ?", userID)
+	if !isOwner && !viewerPrefs.ShowNSFW {
+		query = excludeNSFW(query)
+	}
+
+	var total int64
+	var votes []models.Vote
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch votes"})
+		return
+	}
+	if err := query.Order("votes.updated_at desc, votes.id desc").Offset((page - 1) * limit).Limit(limit).Find(&votes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch votes"})
+		return
+	}
+
+	postIDs := make([]int, 0, len(votes))
+	for _, vote := range votes {
+		postIDs = append(postIDs, vote.PostID)
+	}
+	posts := map[int]models.Post{}
+	var found []models.Post
+	if len(postIDs) > 0 {
+		preloadPostMedia(h.db).Preload("User").Where("id IN ?", postIDs).Find(&found)
+		for _, p := range found {
+			posts[p.ID] = p
+		}
+	}
+	nsfwCommunities := nsfwCommunityIDs(h.db, found)
+
+	responses := make([]gin.H, 0, len(votes))
+	for _, vote := range votes {
+		post, ok := posts[vote.PostID]
+		if !ok {
+			continue
+		}
+		response := gin.H{
+			"id":         post.ID,
+			"title":      post.Title,
+			"kind":       post.Kind,
+			"url":        post.URL,
+			"domain":     post.Domain,
+			"image":      post.Image,
+			"media":      postMediaResponse(post.Media),
+			"community":  post.Community,
+			"user":       post.User,
+			"created_at": post.CreatedAt,
+		}
+		for k, v := range contentFlags(post, nsfwCommunities, viewerPrefs) {
+			response[k] = v
+		}
+		responses = append(responses, gin.H{"vote_type": vote.VoteType, "voted_at": vote.UpdatedAt, "post": response})
+	}
+
+	c.JSON(http.StatusOK, pageResponse(responses, page, limit, total))
+}
+
+// SearchUsers finds users whose username starts with the "q" parameter.
+// Users who hid their profile from search, and accounts pending deletion,
+// are left out.
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	page, limit, ok := parsePage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page and limit must be positive numbers"})
+		return
+	}
+
+	// Escape LIKE wildcards so "_" and "%" match literally
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+	query := h.db.Model(&models.User{}).
+		Where("username ILIKE ?", pattern).
+		Where("deletion_scheduled_for IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM user_preferences WHERE user_preferences.user_id = users.id AND user_preferences.hide_from_search)")
+
+	var total int64
+	var users []models.User
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+	if err := query.Order("username asc").Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(users))
+	for _, user := range users {
+		results = append(results, gin.H{
+			"id":              user.ID,
+			"username":        user.Username,
+			"avatar":          user.Avatar,
+			"avatar_variants": avatarVariantURLs(h.db, user),
+		})
+	}
+
+	c.JSON(http.StatusOK, pageResponse(results, page, limit, total))
+}
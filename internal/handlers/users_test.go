@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+func TestProfilePrivacySettings(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+	h := NewUserHandler(db)
+
+	shy := models.User{Username: "shy_user", Email: "shy@example.com"}
+	open := models.User{Username: "shy_or_not", Email: "open@example.com"}
+	for _, u := range []*models.User{&shy, &open} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	prefs := models.DefaultUserPreferences(shy.ID)
+	prefs.HideVotes, prefs.HideFromSearch = true, true
+	if err := db.Create(&prefs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.Post{Title: "Post", UserID: &open.ID, AuthorID: &open.ID}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Vote{UserID: shy.ID, PostID: post.ID, VoteType: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(handler gin.HandlerFunc, target string, params gin.Params, viewerID int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Params = params
+		if viewerID != 0 {
+			c.Set("user_id", viewerID)
+		}
+		handler(c)
+		return w
+	}
+	votes := func(viewerID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(shy.ID)
+		return request(h.GetUserVotes, "/api/users/"+id+"/votes", gin.Params{{Key: "id", Value: id}}, viewerID)
+	}
+
+	if w := votes(open.ID); w.Code != http.StatusForbidden {
+		t.Errorf("hidden votes shown to another user: status %d", w.Code)
+	}
+	if w := votes(0); w.Code != http.StatusForbidden {
+		t.Errorf("hidden votes shown to a logged-out viewer: status %d", w.Code)
+	}
+	w := votes(shy.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("owner can't see their votes: status %d: %s", w.Code, w.Body.String())
+	}
+	var page struct{ Total int64 }
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Errorf("owner sees %d votes, want 1", page.Total)
+	}
+
+	w = request(h.SearchUsers, "/api/users?q=shy_", nil, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("search: status %d: %s", w.Code, w.Body.String())
+	}
+	var results struct {
+		Items []struct{ Username string }
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Items) != 1 || results.Items[0].Username != "shy_or_not" {
+		t.Errorf("search returned %v, want only shy_or_not", results.Items)
+	}
+}
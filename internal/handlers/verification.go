@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/mailer"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+
+	// Resend limits
+	verificationResendInterval = time.Minute
+	verificationMaxPerHour     = 5
+)
+
+// sendVerificationEmail creates a verification token for the given address and emails it
+func (h *AuthHandler) sendVerificationEmail(user models.User, email string) error {
+	token, err := createUserToken(h.db, user.ID, models.TokenPurposeEmailVerification, email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", appURL(), token)
+	sendEmail(h.mailer, mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours. If you didn't create an account, you can ignore this email.\n",
+			user.Username, link,
+		),
+	})
+	return nil
+}
+
+// VerifyEmail confirms an email address using the emailed token. It also
+// completes email changes started from the account settings.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := consumeUserToken(h.db, input.Token, models.TokenPurposeEmailVerification, models.TokenPurposeEmailChange)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, token.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	oldEmail := user.Email
+	if token.Purpose == models.TokenPurposeEmailChange {
+		// The address may have been claimed since the change was requested
+		var count int64
+		h.db.Model(&models.User{}).Where("email = ? AND id <> ?", token.Email, user.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already in use"})
+			return
+		}
+		user.Email = token.Email
+	} else if token.Email != user.Email {
+		// The token only proves ownership of the address it was sent to
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	now := time.Now()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	if err := h.db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	if token.Purpose == models.TokenPurposeEmailChange {
+		recordSecurityEvent(h.db, c, user.ID, models.SecurityEventEmailChanged, fmt.Sprintf("%s -> %s", oldEmail, user.Email))
+		sendEmail(h.mailer, mailer.Message{
+			To:      oldEmail,
+			Subject: "Your email address was changed",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nThe email address on your account was changed to %s.\n\nIf this wasn't you, contact support immediately.\n",
+				user.Username, user.Email,
+			),
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully", "email": user.Email})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification emails a fresh verification token to the current user (rate limited)
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, ok := extractUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	// Rate limit: one email per minute and a handful per hour
+	var recent []models.UserToken
+	h.db.Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, models.TokenPurposeEmailVerification, time.Now().Add(-time.Hour)).
+		Order("created_at desc").Find(&recent)
+
+	if len(recent) > 0 {
+		if wait := verificationResendInterval - time.Since(recent[0].CreatedAt); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+			return
+		}
+	}
+	if len(recent) >= verificationMaxPerHour {
+		wait := time.Hour - time.Since(recent[len(recent)-1].CreatedAt)
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many verification emails requested, try again later"})
+		return
+	}
+
+	if err := h.sendVerificationEmail(user, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
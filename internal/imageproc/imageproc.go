@@ -0,0 +1,172 @@
+// Package imageproc prepares uploaded images for serving: it removes
+// metadata such as EXIF GPS coordinates, renders resized variants and a
+// blurred preview, and computes a perceptual hash.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+// MaxPixels guards against decompression bombs: small files that decode to
+// enormous images
+const MaxPixels = 50_000_000
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// VariantSpec describes one resized copy of an image
+type VariantSpec struct {
+	Name    string
+	Width   int
+	Height  int  // Only used when Crop is set
+	Crop    bool // Fill Width x Height exactly, cropping from the centre
+	Blur    float64
+	Quality int
+}
+
+// DefaultVariants are rendered for every image. Fitted variants wider than
+// the original are skipped rather than upscaled.
+var DefaultVariants = []VariantSpec{
+	{Name: "thumbnail", Width: 160, Height: 160, Crop: true, Quality: 80},
+	{Name: "small", Width: 320, Quality: 82},
+	{Name: "medium", Width: 640, Quality: 85},
+	{Name: "large", Width: 1280, Quality: 85},
+	// Shown in place of NSFW and spoiler images until the viewer opts in
+	{Name: "blurred", Width: 640, Blur: 30, Quality: 70},
+}
+
+// Variant is a rendered JPEG
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Result is the outcome of processing an image
+type Result struct {
+	Width  int
+	Height int
+	Hash   uint64 // Difference hash, see DHash
+
+	// Original is the upload re-encoded without metadata, in its original
+	// format, or nil if the upload can be served as it is
+	Original []byte
+
+	Variants []Variant
+}
+
+// Process decodes an image, applies its EXIF orientation and renders the
+// metadata-free original and the variants. contentType is the sniffed type
+// of data.
+func Process(data []byte, contentType string, specs []VariantSpec) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	result := &Result{Width: bounds.Dx(), Height: bounds.Dy(), Hash: DHash(img)}
+
+	switch contentType {
+	case "image/jpeg":
+		// Re-encoding drops EXIF, XMP and IPTC blocks and bakes in the orientation
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92}); err != nil {
+			return nil, err
+		}
+		result.Original = buf.Bytes()
+	case "image/png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		result.Original = buf.Bytes()
+	case "image/webp":
+		stripped, err := StripWebPMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		result.Original = stripped
+	}
+	// GIFs can't carry EXIF and re-encoding would drop their animation
+
+	// Variants are JPEGs, so flatten transparency onto white first
+	flat := imaging.New(result.Width, result.Height, color.White)
+	flat = imaging.Overlay(flat, img, image.Point{}, 1)
+
+	for _, spec := range specs {
+		var out *image.NRGBA
+		switch {
+		case spec.Crop:
+			out = imaging.Fill(flat, spec.Width, spec.Height, imaging.Center, imaging.Lanczos)
+		case spec.Width >= result.Width && spec.Blur == 0:
+			continue
+		case spec.Width >= result.Width:
+			out = imaging.Clone(flat)
+		default:
+			out = imaging.Resize(flat, spec.Width, 0, imaging.Lanczos)
+		}
+		if spec.Blur > 0 {
+			out = imaging.Blur(out, spec.Blur)
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: spec.Quality}); err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, Variant{
+			Name:   spec.Name,
+			Width:  out.Bounds().Dx(),
+			Height: out.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+
+	return result, nil
+}
+
+// DHash computes a 64-bit difference hash: the image is shrunk to 9x8
+// grayscale pixels and each bit records whether a pixel is brighter than
+// its right-hand neighbour. Resized or re-encoded copies of the same
+// picture have hashes a small Hamming distance apart.
+func DHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance is the number of differing bits between two hashes. Under 10
+// usually means the same picture.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,163 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testImage draws a gradient with a few shapes so the hash has detail to work with
+func testImage(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255}
+			if (x/(w/4)+y/(h/4))%2 == 0 {
+				c.B = 20
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF inserts an APP1 segment carrying a fake GPS marker after the SOI
+func withEXIF(data []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("GPSLatitude=51.5007")...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessJPEG(t *testing.T) {
+	data := withEXIF(encodeJPEG(t, testImage(800, 600)))
+
+	result, err := Process(data, "image/jpeg", DefaultVariants)
+	if err != nil {
+		t.Fatalf("Process() error: %v", err)
+	}
+	if result.Width != 800 || result.Height != 600 {
+		t.Errorf("dimensions = %dx%d, want 800x600", result.Width, result.Height)
+	}
+	if bytes.Contains(result.Original, []byte("GPSLatitude")) {
+		t.Error("original still contains EXIF data")
+	}
+
+	got := map[string]Variant{}
+	for _, v := range result.Variants {
+		got[v.Name] = v
+	}
+	if _, ok := got["large"]; ok {
+		t.Error("large variant should not upscale an 800px image")
+	}
+	for name, want := range map[string][2]int{
+		"thumbnail": {160, 160},
+		"small":     {320, 240},
+		"medium":    {640, 480},
+		"blurred":   {640, 480},
+	} {
+		v, ok := got[name]
+		if !ok {
+			t.Errorf("missing %s variant", name)
+			continue
+		}
+		if v.Width != want[0] || v.Height != want[1] {
+			t.Errorf("%s = %dx%d, want %dx%d", name, v.Width, v.Height, want[0], want[1])
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(v.Data)); err != nil {
+			t.Errorf("%s is not a valid JPEG: %v", name, err)
+		}
+	}
+}
+
+func TestProcessRejectsHugeImages(t *testing.T) {
+	// Only the header is read, so a tiny PNG claiming huge dimensions is enough
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)), imaging.PNG); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:20], 100_000)
+	binary.BigEndian.PutUint32(data[20:24], 100_000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data, "image/png", DefaultVariants); err != ErrTooLarge {
+		t.Fatalf("Process() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestDHashSurvivesResizing(t *testing.T) {
+	img := testImage(800, 600)
+	small := imaging.Resize(img, 200, 150, imaging.Lanczos)
+	other := imaging.FlipH(img)
+
+	if d := Distance(DHash(img), DHash(small)); d > 5 {
+		t.Errorf("distance between resized copies = %d, want <= 5", d)
+	}
+	if d := Distance(DHash(img), DHash(other)); d < 10 {
+		t.Errorf("distance between different images = %d, want >= 10", d)
+	}
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	chunk := func(fourCC string, body []byte) []byte {
+		out := append([]byte(fourCC), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(body)))
+		out = append(out, body...)
+		if len(body)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	var body []byte
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("VP8 ", []byte("image-data"))...)
+	body = append(body, chunk("EXIF", []byte("GPSLatitude"))...)
+	body = append(body, chunk("XMP ", []byte("<x/>"))...)
+
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), body...)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+
+	out, err := StripWebPMetadata(data)
+	if err != nil {
+		t.Fatalf("StripWebPMetadata() error: %v", err)
+	}
+	if bytes.Contains(out, []byte("GPSLatitude")) || bytes.Contains(out, []byte("XMP ")) {
+		t.Error("metadata chunks were not removed")
+	}
+	if !bytes.Contains(out, []byte("image-data")) {
+		t.Error("image chunk was removed")
+	}
+	if flags := out[20]; flags&(webpFlagEXIF|webpFlagXMP) != 0 {
+		t.Errorf("VP8X flags = %#x, metadata bits still set", flags)
+	}
+	if size := binary.LittleEndian.Uint32(out[4:8]); int(size) != len(out)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(out)-8)
+	}
+
+	if _, err := StripWebPMetadata([]byte("not a webp")); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
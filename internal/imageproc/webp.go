@@ -0,0 +1,54 @@
+package imageproc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidWebP = errors.New("invalid WebP file")
+
+// VP8X flags for metadata chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// StripWebPMetadata removes the EXIF and XMP chunks from a WebP file
+// without re-encoding it (Go can decode WebP but not encode it)
+func StripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, errInvalidWebP
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2 // Chunks are padded to an even length
+		if size < 0 || end > len(data) || end < pos {
+			return nil, errInvalidWebP
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// Dropped
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+)
+
+// DeletedAuthor replaces the author name on content of deleted accounts
+const DeletedAuthor = "[deleted]"
+
+// Accounts purged per run, so a backlog can't hold one transaction open for long
+const purgeBatchSize = 100
+
+// PurgeDeletedAccounts permanently deletes accounts whose deletion grace
+// period is over and returns how many were purged
+func PurgeDeletedAccounts(ctx context.Context, db *gorm.DB, store storage.Storage) (int, error) {
+	var ids []int
+	err := db.WithContext(ctx).Model(&models.User{}).
+		Where("deletion_scheduled_for <= ?", time.Now()).
+		Order("deletion_scheduled_for asc").
+		Limit(purgeBatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		ok, err := purgeAccount(ctx, db, store, id)
+		if err != nil {
+			// Keep going so one broken account can't hold up the rest
+			log.Printf("Failed to purge account %d: %v", id, err)
+			continue
+		}
+		if ok {
+			purged++
+		}
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted accounts", purged)
+	}
+	return purged, nil
+}
+
+// purgeAccount anonymises a user's posts and comments, removes everything
+// else tied to the account and deletes it. The username stays reserved
+// forever so nobody can impersonate the old account; the email is freed.
+// Uploads used in posts stay with the posts, the rest are deleted.
+func purgeAccount(ctx context.Context, db *gorm.DB, store storage.Storage, userID int) (bool, error) {
+	purged := false
+	var exportFiles, mediaKeys []string
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Re-check under lock in case the user logged in and cancelled meanwhile
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deletion_scheduled_for <= ?", userID, time.Now()).
+			First(&user).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Content stays up but is no longer linked to the account
+		if err := tx.Model(&models.Post{}).
+			Where("user_id = ? OR author_id = ?", user.ID, user.ID).
+			Updates(map[string]interface{}{"author": DeletedAuthor, "user_id": nil, "author_id": nil}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Comment{}).
+			Where("author_id = ?", user.ID).
+			Updates(map[string]interface{}{"author": DeletedAuthor, "author_id": nil}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Revision{}).Where("editor_id = ?", user.ID).Update("editor_id", 0).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("follower_id = ? OR following_id = ?", user.ID, user.ID).Delete(&models.Follow{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blocker_id = ? OR blocked_id = ?", user.ID, user.ID).Delete(&models.Block{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.DataExport{}).Where("user_id = ? AND file_path <> ''", user.ID).Pluck("file_path", &exportFiles).Error; err != nil {
+			return err
+		}
+
+		unused := tx.Model(&models.Media{}).
+			Where("user_id = ? AND NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.media_id = media.id)", user.ID)
+		if err := unused.Session(&gorm.Session{}).Pluck("key", &mediaKeys).Error; err != nil {
+			return err
+		}
+		var variantKeys []string
+		unusedIDs := unused.Session(&gorm.Session{}).Select("id")
+		if err := tx.Model(&models.MediaVariant{}).Where("media_id IN (?)", unusedIDs).Pluck("key", &variantKeys).Error; err != nil {
+			return err
+		}
+		mediaKeys = append(mediaKeys, variantKeys...)
+		if err := tx.Where("media_id IN (?)", unusedIDs).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		if err := unused.Session(&gorm.Session{}).Delete(&models.Media{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Media{}).Where("user_id = ?", user.ID).Update("user_id", 0).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.Vote{},
+			&models.Session{},
+			&models.UserIdentity{},
+			&models.WebAuthnCredential{},
+			&models.WebAuthnSession{},
+			&models.RecoveryCode{},
+			&models.PhoneCode{},
+			&models.UserToken{},
+			&models.SecurityEvent{},
+			&models.DataExport{},
+			&models.UserPreferences{},
+			&models.SavedItem{},
+			&models.SavedCollection{},
+			&models.HiddenPost{},
+			&models.PostView{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("username = ?", user.Username).Delete(&models.ReservedUsername{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.ReservedUsername{Username: user.Username, UserID: user.ID}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		purged = true
+		return nil
+	})
+
+	// Files are only removed once the rows pointing at them are gone
+	if err == nil {
+		for _, path := range exportFiles {
+			removeExportFile(path)
+		}
+		deleteMediaFiles(ctx, store, mediaKeys)
+	}
+	return purged, err
+}
@@ -0,0 +1,146 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/database"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+)
+
+// testDB starts a Postgres container with the full schema. Foreign keys
+// matter here, so it doesn't use an in-memory stand-in.
+func testDB(t *testing.T) *gorm.DB {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	container, err := tcpostgres.Run(ctx, "postgres:latest",
+		tcpostgres.WithDatabase("test"),
+		tcpostgres.WithUsername("user"),
+		tcpostgres.WithPassword("password"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(database.Models()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPurgeAccount(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x", DeletionScheduledFor: &past}
+	bob := models.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	for _, u := range []*models.User{&alice, &bob} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alicePost := models.Post{Title: "mine", Author: "alice", UserID: &alice.ID, AuthorID: &alice.ID}
+	bobPost := models.Post{Title: "theirs", Author: "bob", UserID: &bob.ID, AuthorID: &bob.ID}
+	for _, p := range []*models.Post{&alicePost, &bobPost} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	aliceComment := models.Comment{Body: "hi", Author: "alice", AuthorID: &alice.ID, PostID: bobPost.ID}
+	bobComment := models.Comment{Body: "hello", Author: "bob", AuthorID: &bob.ID, PostID: alicePost.ID}
+	for _, cm := range []*models.Comment{&aliceComment, &bobComment} {
+		if err := db.Create(cm).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := []interface{}{
+		&models.Vote{UserID: alice.ID, PostID: bobPost.ID, VoteType: 1},
+		&models.Vote{UserID: bob.ID, PostID: alicePost.ID, VoteType: 1},
+		&models.Follow{FollowerID: alice.ID, FollowingID: bob.ID},
+		&models.Follow{FollowerID: bob.ID, FollowingID: alice.ID},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := PurgeDeletedAccounts(ctx, db, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d accounts, want 1", purged)
+	}
+
+	if err := db.First(&models.User{}, alice.ID).Error; err != gorm.ErrRecordNotFound {
+		t.Errorf("purged user still exists: %v", err)
+	}
+	if err := db.Where("username = ?", "alice").First(&models.ReservedUsername{}).Error; err != nil {
+		t.Errorf("username was not reserved: %v", err)
+	}
+
+	var post models.Post
+	if err := db.First(&post, alicePost.ID).Error; err != nil {
+		t.Fatalf("post was deleted: %v", err)
+	}
+	if post.UserID != nil || post.AuthorID != nil || post.Author != DeletedAuthor {
+		t.Errorf("post not anonymised: user_id=%v author_id=%v author=%q", post.UserID, post.AuthorID, post.Author)
+	}
+	var comment models.Comment
+	if err := db.First(&comment, aliceComment.ID).Error; err != nil {
+		t.Fatalf("comment was deleted: %v", err)
+	}
+	if comment.AuthorID != nil || comment.Author != DeletedAuthor {
+		t.Errorf("comment not anonymised: author_id=%v author=%q", comment.AuthorID, comment.Author)
+	}
+
+	var count int64
+	db.Model(&models.Vote{}).Where("user_id = ?", alice.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d votes left for the purged user", count)
+	}
+	db.Model(&models.Follow{}).Where("follower_id = ? OR following_id = ?", alice.ID, alice.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d follows left for the purged user", count)
+	}
+
+	// The other user's content is untouched
+	if err := db.First(&post, bobPost.ID).Error; err != nil || post.AuthorID == nil || *post.AuthorID != bob.ID {
+		t.Errorf("other user's post changed: %v", err)
+	}
+	if err := db.First(&comment, bobComment.ID).Error; err != nil || comment.AuthorID == nil || *comment.AuthorID != bob.ID {
+		t.Errorf("other user's comment changed: %v", err)
+	}
+	db.Model(&models.Vote{}).Where("user_id = ?", bob.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("other user has %d votes, want 1", count)
+	}
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// Exports still unfinished after this long were interrupted, e.g. by a restart
+const staleDataExportAge = time.Hour
+
+// CleanupDataExports deletes expired export archives and marks interrupted
+// exports as failed so the user can request a new one
+func CleanupDataExports(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	var expired []models.DataExport
+	if err := db.Where("status = ? AND expires_at <= ?", models.DataExportReady, now).Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, export := range expired {
+		removeExportFile(export.FilePath)
+		if err := db.Model(&export).Updates(map[string]interface{}{"status": models.DataExportExpired, "file_path": ""}).Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Model(&models.DataExport{}).
+		Where("status IN ? AND created_at <= ?", []string{models.DataExportPending, models.DataExportProcessing}, now.Add(-staleDataExportAge)).
+		Update("status", models.DataExportFailed).Error
+}
+
+func removeExportFile(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove data export %s: %v", path, err)
+	}
+}
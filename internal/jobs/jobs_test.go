@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEveryRunsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, "test", time.Millisecond, func(ctx context.Context) error {
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return errors.New("keeps going after errors")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Every did not return after the context was cancelled")
+	}
+
+	if got := runs.Load(); got < 3 {
+		t.Fatalf("expected at least 3 runs, got %d", got)
+	}
+}
@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/unfurl"
+)
+
+// Previews still being fetched after this long are assumed to have been
+// interrupted by a restart and are fetched again
+const linkPreviewTimeout = 5 * time.Minute
+
+// Thumbnail types we keep, keyed by sniffed content type
+var previewImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UnfurlPendingLinks fetches previews that are waiting or whose fetch was
+// interrupted
+func UnfurlPendingLinks(ctx context.Context, db *gorm.DB, store storage.Storage, fetcher *unfurl.Fetcher) error {
+	var ids []int
+	err := db.WithContext(ctx).Model(&models.LinkPreview{}).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.LinkPreviewPending, models.LinkPreviewFetching, time.Now().Add(-linkPreviewTimeout)).
+		Order("post_id asc").
+		Limit(100).
+		Pluck("post_id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := UnfurlLink(ctx, db, store, fetcher, id); err != nil && ctx.Err() != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnfurlLink fetches the preview of a link post and downloads its
+// thumbnail through the media pipeline. Pages that can't be fetched leave
+// the post without a preview.
+func UnfurlLink(ctx context.Context, db *gorm.DB, store storage.Storage, fetcher *unfurl.Fetcher, postID int) error {
+	// Claim the row so concurrent runs don't fetch the same page
+	claim := db.WithContext(ctx).Model(&models.LinkPreview{}).
+		Where("post_id = ?", postID).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.LinkPreviewPending, models.LinkPreviewFetching, time.Now().Add(-linkPreviewTimeout)).
+		Update("status", models.LinkPreviewFetching)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error
+	}
+
+	var post models.Post
+	if err := db.WithContext(ctx).First(&post, postID).Error; err != nil {
+		return err
+	}
+
+	preview, err := fetcher.Fetch(ctx, post.URL)
+	if err != nil {
+		log.Printf("Failed to fetch preview of post %d: %v", postID, err)
+		if ctx.Err() == nil {
+			db.Model(&models.LinkPreview{}).Where("post_id = ?", postID).Update("status", models.LinkPreviewFailed)
+		}
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"status":      models.LinkPreviewReady,
+		"title":       preview.Title,
+		"description": preview.Description,
+		"site_name":   preview.SiteName,
+		"image_url":   preview.ImageURL,
+	}
+	if preview.ImageURL != "" {
+		// A missing thumbnail doesn't make the rest of the preview useless
+		mediaID, err := storePreviewImage(ctx, db, store, fetcher, preview.ImageURL)
+		if err != nil {
+			log.Printf("Failed to download preview image of post %d: %v", postID, err)
+		} else {
+			updates["media_id"] = mediaID
+		}
+	}
+
+	return db.WithContext(ctx).Model(&models.LinkPreview{}).Where("post_id = ?", postID).Updates(updates).Error
+}
+
+// storePreviewImage downloads an image and stores it as an upload with no
+// owner, so it stays when the post's author deletes their account
+func storePreviewImage(ctx context.Context, db *gorm.DB, store storage.Storage, fetcher *unfurl.Fetcher, imageURL string) (int, error) {
+	data, err := fetcher.FetchImage(ctx, imageURL)
+	if err != nil {
+		return 0, err
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := previewImageTypes[contentType]
+	if !ok {
+		return 0, fmt.Errorf("unsupported image type %q", contentType)
+	}
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return 0, err
+	}
+	key := "previews/" + hex.EncodeToString(name) + ext
+
+	if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return 0, err
+	}
+
+	media := models.Media{
+		Kind:        models.MediaKindImage,
+		Key:         key,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Status:      models.MediaStatusPending,
+	}
+	if err := db.WithContext(ctx).Create(&media).Error; err != nil {
+		store.Delete(ctx, key)
+		return 0, err
+	}
+
+	// Thumbnails are small, so process them now rather than leave them for
+	// the media sweep
+	if err := ProcessMedia(ctx, db, store, media.ID); err != nil {
+		// Don't serve files that only claim to be images
+		db.Delete(&media)
+		store.Delete(ctx, key)
+		return 0, err
+	}
+	return media.ID, nil
+}
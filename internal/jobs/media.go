@@ -0,0 +1,208 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/imageproc"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/storage"
+)
+
+// Uploads still processing after this long are assumed to have been
+// interrupted by a restart and are picked up again
+const mediaProcessingTimeout = 10 * time.Minute
+
+// ProcessPendingMedia processes images that are waiting or whose processing
+// was interrupted. Uploads are normally processed straight away; this sweep
+// catches the rest.
+func ProcessPendingMedia(ctx context.Context, db *gorm.DB, store storage.Storage) error {
+	var ids []int
+	err := db.WithContext(ctx).Model(&models.Media{}).
+		Where("kind = ?", models.MediaKindImage).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.MediaStatusPending, models.MediaStatusProcessing, time.Now().Add(-mediaProcessingTimeout)).
+		Order("id asc").
+		Limit(100).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := ProcessMedia(ctx, db, store, id); err != nil && ctx.Err() != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProcessMedia strips metadata from an uploaded image, stores its resized
+// variants and records its dimensions and perceptual hash. Images that
+// can't be processed are marked failed and their file is deleted, as it
+// may still hold EXIF and GPS data.
+func ProcessMedia(ctx context.Context, db *gorm.DB, store storage.Storage, mediaID int) error {
+	// Claim the row so concurrent runs don't process the same image
+	claim := db.WithContext(ctx).Model(&models.Media{}).
+		Where("id = ? AND kind = ?", mediaID, models.MediaKindImage).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.MediaStatusPending, models.MediaStatusProcessing, time.Now().Add(-mediaProcessingTimeout)).
+		Update("status", models.MediaStatusProcessing)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error
+	}
+
+	var media models.Media
+	if err := db.WithContext(ctx).First(&media, mediaID).Error; err != nil {
+		return err
+	}
+
+	if err := processMedia(ctx, db, store, &media); err != nil {
+		log.Printf("Failed to process media %d: %v", media.ID, err)
+		if ctx.Err() == nil {
+			db.Model(&media).Update("status", models.MediaStatusFailed)
+			deleteMediaFiles(ctx, store, []string{media.Key})
+		}
+		return err
+	}
+	return nil
+}
+
+func processMedia(ctx context.Context, db *gorm.DB, store storage.Storage, media *models.Media) error {
+	obj, err := store.Open(ctx, media.Key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return err
+	}
+
+	result, err := imageproc.Process(data, media.ContentType, imageproc.DefaultVariants)
+	if err != nil {
+		return err
+	}
+
+	// Overwrite the upload so the original with its GPS coordinates is gone
+	size := media.Size
+	if result.Original != nil {
+		if err := store.Put(ctx, media.Key, bytes.NewReader(result.Original), int64(len(result.Original)), media.ContentType); err != nil {
+			return fmt.Errorf("storing stripped original: %w", err)
+		}
+		size = int64(len(result.Original))
+	}
+
+	// Variants sit next to the original: "12/abc.jpg" gets "12/abc_thumbnail.jpg"
+	base := strings.TrimSuffix(media.Key, path.Ext(media.Key))
+	variants := make([]models.MediaVariant, 0, len(result.Variants))
+	for _, v := range result.Variants {
+		key := base + "_" + v.Name + ".jpg"
+		if err := store.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), "image/jpeg"); err != nil {
+			return fmt.Errorf("storing %s variant: %w", v.Name, err)
+		}
+		variants = append(variants, models.MediaVariant{
+			MediaID:     media.ID,
+			Name:        v.Name,
+			Key:         key,
+			ContentType: "image/jpeg",
+			Width:       v.Width,
+			Height:      v.Height,
+			Size:        int64(len(v.Data)),
+		})
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variants) > 0 {
+			if err := tx.Create(&variants).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(media).Updates(map[string]interface{}{
+			"status": models.MediaStatusReady,
+			"width":  result.Width,
+			"height": result.Height,
+			"hash":   fmt.Sprintf("%016x", result.Hash),
+			"size":   size,
+		}).Error
+	})
+}
+
+// deleteMediaFiles removes stored files, logging the ones that fail
+func deleteMediaFiles(ctx context.Context, store storage.Storage, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete media %q: %v", key, err)
+		}
+	}
+}
+
+// mediaUnused matches uploads no post, link preview or avatar points at
+const mediaUnused = `NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.media_id = media.id)
+	AND NOT EXISTS (SELECT 1 FROM link_previews WHERE link_previews.media_id = media.id)
+	AND NOT EXISTS (SELECT 1 FROM users WHERE users.avatar_media_id = media.id)`
+
+// DeleteUnusedMedia deletes the given uploads with their variants and
+// files, skipping any that are still in use
+func DeleteUnusedMedia(ctx context.Context, db *gorm.DB, store storage.Storage, mediaIDs []int) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+	return deleteMedia(ctx, db, store, db.Where("id IN ? AND "+mediaUnused, mediaIDs))
+}
+
+// Link preview thumbnails are stored before the preview points at them,
+// so only ones older than this are treated as orphaned
+const orphanedMediaAge = time.Hour
+
+// CleanupOrphanedMedia deletes link preview thumbnails and other uploads
+// without an owner that nothing uses any more. Uploads that belong to a
+// user are kept, as they may still be attached to a post.
+func CleanupOrphanedMedia(ctx context.Context, db *gorm.DB, store storage.Storage) error {
+	return deleteMedia(ctx, db, store,
+		db.Where("user_id = 0 AND created_at < ? AND "+mediaUnused, time.Now().Add(-orphanedMediaAge)))
+}
+
+// deleteMedia deletes the media rows matching a condition, their variants
+// and, once the rows are gone, their files
+func deleteMedia(ctx context.Context, db *gorm.DB, store storage.Storage, cond *gorm.DB) error {
+	var keys []string
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var media []models.Media
+		if err := tx.Preload("Variants").Where(cond).Find(&media).Error; err != nil {
+			return err
+		}
+		if len(media) == 0 {
+			return nil
+		}
+
+		ids := make([]int, 0, len(media))
+		for _, m := range media {
+			ids = append(ids, m.ID)
+			keys = append(keys, m.Key)
+			for _, v := range m.Variants {
+				keys = append(keys, v.Key)
+			}
+		}
+		if err := tx.Where("media_id IN ?", ids).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Media{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	deleteMediaFiles(ctx, store, keys)
+	return nil
+}
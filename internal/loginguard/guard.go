@@ -0,0 +1,175 @@
+package loginguard
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Record is the failure state stored for one key (an account or an IP)
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists failed login attempts. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the record for key, or a zero Record if there is none
+	Get(ctx context.Context, key string) (Record, error)
+	// Increment adds a failure and returns the new count. Failures older
+	// than window are forgotten first.
+	Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Lock blocks the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets all failures for key
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy controls how quickly a key is slowed down and locked out
+type Policy struct {
+	FreeAttempts     int           // Failures allowed before any delay
+	BaseDelay        time.Duration // Delay after the first failure past FreeAttempts, doubled each time
+	MaxDelay         time.Duration // Upper bound for the exponential delay
+	LockoutThreshold int           // Failures that trigger a full lockout
+	LockoutDuration  time.Duration
+	Window           time.Duration // Failures older than this are forgotten
+}
+
+// Delay returns how long a key must wait after reaching the given number of failures
+func (p Policy) Delay(failures int) time.Duration {
+	if failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures-p.FreeAttempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+var (
+	// DefaultAccountPolicy applies to failures against a single account
+	DefaultAccountPolicy = Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+		Window:           24 * time.Hour,
+	}
+
+	// DefaultIPPolicy applies to failures from a single IP across all accounts
+	DefaultIPPolicy = Policy{
+		FreeAttempts:     20,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 100,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}
+)
+
+// Guard tracks failed logins per account and per IP
+type Guard struct {
+	store   Store
+	account Policy
+	ip      Policy
+	now     func() time.Time
+}
+
+func NewGuard(store Store, account, ip Policy) *Guard {
+	return &Guard{store: store, account: account, ip: ip, now: time.Now}
+}
+
+// NewFromEnv builds a guard with the default policies. LOGIN_GUARD_STORE
+// selects "postgres" (shared between instances) or "memory" (default).
+func NewFromEnv(db *gorm.DB) *Guard {
+	var store Store
+	if os.Getenv("LOGIN_GUARD_STORE") == "postgres" {
+		store = NewPostgresStore(db)
+	} else {
+		store = NewMemoryStore()
+	}
+	return NewGuard(store, DefaultAccountPolicy, DefaultIPPolicy)
+}
+
+func accountKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller must wait before trying again, or
+// zero if the attempt may proceed
+func (g *Guard) Check(ctx context.Context, account, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{accountKey(account), ipKey(ip)} {
+		record, err := g.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if remaining := record.LockedUntil.Sub(g.now()); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt. It reports whether this failure just
+// locked the account out, and how long the caller must now wait.
+func (g *Guard) Fail(ctx context.Context, account, ip string) (bool, time.Duration, error) {
+	now := g.now()
+	accountLocked := false
+	var wait time.Duration
+
+	checks := []struct {
+		key    string
+		policy Policy
+	}{
+		{accountKey(account), g.account},
+		{ipKey(ip), g.ip},
+	}
+
+	for i, check := range checks {
+		failures, err := g.store.Increment(ctx, check.key, now, check.policy.Window)
+		if err != nil {
+			return false, 0, err
+		}
+
+		delay := check.policy.Delay(failures)
+		if delay == 0 {
+			continue
+		}
+		if err := g.store.Lock(ctx, check.key, now.Add(delay)); err != nil {
+			return false, 0, err
+		}
+		if delay > wait {
+			wait = delay
+		}
+		if i == 0 && failures == check.policy.LockoutThreshold {
+			accountLocked = true
+		}
+	}
+
+	return accountLocked, wait, nil
+}
+
+// Succeed clears the account's failures after a successful login. IP
+// failures are kept so one valid account can't be used to reset them.
+func (g *Guard) Succeed(ctx context.Context, account string) error {
+	return g.store.Reset(ctx, accountKey(account))
+}
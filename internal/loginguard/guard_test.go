@@ -0,0 +1,120 @@
+package loginguard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestGuard() (*Guard, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := NewGuard(NewMemoryStore(), DefaultAccountPolicy, DefaultIPPolicy)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := DefaultAccountPolicy
+	cases := map[int]time.Duration{
+		1:  0,
+		3:  0,
+		4:  time.Second,
+		5:  2 * time.Second,
+		6:  4 * time.Second,
+		9:  32 * time.Second,
+		10: 30 * time.Minute,
+		50: 30 * time.Minute,
+	}
+	for failures, want := range cases {
+		if got := p.Delay(failures); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	capped := Policy{FreeAttempts: 0, BaseDelay: time.Second, MaxDelay: 10 * time.Second, LockoutThreshold: 100}
+	if got := capped.Delay(20); got != 10*time.Second {
+		t.Errorf("expected delay capped at MaxDelay, got %v", got)
+	}
+}
+
+func TestGuardLocksAccountAfterThreshold(t *testing.T) {
+	g, now := newTestGuard()
+	ctx := context.Background()
+
+	var locked bool
+	for i := 1; i <= DefaultAccountPolicy.LockoutThreshold; i++ {
+		// Wait out any backoff so only the lockout is tested
+		wait, _ := g.Check(ctx, "alice@example.com", "10.0.0.1")
+		*now = now.Add(wait)
+
+		var err error
+		locked, _, err = g.Fail(ctx, "Alice@Example.com", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("Fail() returned error: %v", err)
+		}
+		if locked && i != DefaultAccountPolicy.LockoutThreshold {
+			t.Fatalf("account locked after %d failures", i)
+		}
+	}
+	if !locked {
+		t.Fatal("expected account to be locked at the threshold")
+	}
+
+	wait, err := g.Check(ctx, "alice@example.com", "10.0.0.2")
+	if err != nil || wait != DefaultAccountPolicy.LockoutDuration {
+		t.Fatalf("Check() = %v, %v; want %v", wait, err, DefaultAccountPolicy.LockoutDuration)
+	}
+
+	*now = now.Add(DefaultAccountPolicy.LockoutDuration)
+	if wait, _ := g.Check(ctx, "alice@example.com", "10.0.0.2"); wait != 0 {
+		t.Fatalf("expected lockout to expire, still waiting %v", wait)
+	}
+}
+
+func TestGuardSucceedResetsAccount(t *testing.T) {
+	g, _ := newTestGuard()
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		g.Fail(ctx, "bob@example.com", "10.0.0.1")
+	}
+	if wait, _ := g.Check(ctx, "bob@example.com", "10.0.0.9"); wait == 0 {
+		t.Fatal("expected backoff after repeated failures")
+	}
+
+	if err := g.Succeed(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("Succeed() returned error: %v", err)
+	}
+	if wait, _ := g.Check(ctx, "bob@example.com", "10.0.0.9"); wait != 0 {
+		t.Fatalf("expected no wait after success, got %v", wait)
+	}
+}
+
+func TestGuardThrottlesIPAcrossAccounts(t *testing.T) {
+	g, _ := newTestGuard()
+	ctx := context.Background()
+
+	for i := 0; i <= DefaultIPPolicy.FreeAttempts; i++ {
+		g.Fail(ctx, "user"+string(rune('a'+i))+"@example.com", "10.0.0.7")
+	}
+
+	if wait, _ := g.Check(ctx, "fresh@example.com", "10.0.0.7"); wait == 0 {
+		t.Fatal("expected IP to be throttled after many failures across accounts")
+	}
+	if wait, _ := g.Check(ctx, "fresh@example.com", "10.0.0.8"); wait != 0 {
+		t.Fatalf("other IPs should not be throttled, got %v", wait)
+	}
+}
+
+func TestMemoryStoreForgetsOldFailures(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	s.Increment(ctx, "k", start, time.Hour)
+	s.Increment(ctx, "k", start.Add(time.Minute), time.Hour)
+	n, _ := s.Increment(ctx, "k", start.Add(3*time.Hour), time.Hour)
+	if n != 1 {
+		t.Fatalf("expected failures to reset after the window, got %d", n)
+	}
+}
@@ -0,0 +1,72 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneEvery controls how often stale entries are swept from memory
+const pruneEvery = 1000
+
+// MemoryStore keeps attempts in process memory. Suitable for a single instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	writes  int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	if now.Sub(record.LastFailureAt) > window {
+		record.Failures = 0
+	}
+	record.Failures++
+	record.LastFailureAt = now
+	s.records[key] = record
+
+	s.writes++
+	if s.writes%pruneEvery == 0 {
+		s.prune(now)
+	}
+	return record.Failures, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	record.LockedUntil = until
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// prune drops entries that are unlocked and haven't failed for a day
+func (s *MemoryStore) prune(now time.Time) {
+	for key, record := range s.records {
+		if now.After(record.LockedUntil) && now.Sub(record.LastFailureAt) > 24*time.Hour {
+			delete(s.records, key)
+		}
+	}
+}
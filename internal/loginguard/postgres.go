@@ -0,0 +1,65 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// PostgresStore keeps attempts in the login_attempts table so every
+// instance sees the same counters
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Record, error) {
+	var attempt models.LoginAttempt
+	err := s.db.WithContext(ctx).Where("key = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(attempt), nil
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	var failures int
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`,
+		key, now, now.Add(-window),
+	).Scan(&failures).Error
+	return failures, err
+}
+
+func (s *PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toRecord(attempt models.LoginAttempt) Record {
+	record := Record{Failures: attempt.Failures, LastFailureAt: attempt.LastFailureAt}
+	if attempt.LockedUntil != nil {
+		record.LockedUntil = *attempt.LockedUntil
+	}
+	return record
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+)
+
+// Message is a single plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv builds a mailer from the MAILER_DRIVER environment variable.
+// "smtp" delivers through SMTP_HOST; anything else falls back to the log
+// mailer so local development never needs a real mail server.
+func NewFromEnv() Mailer {
+	switch os.Getenv("MAILER_DRIVER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	default:
+		log.Println("📧 Using log mailer, emails will not be delivered")
+		return &LogMailer{Dir: os.Getenv("MAILER_LOG_DIR")}
+	}
+}
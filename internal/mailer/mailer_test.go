@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	m := &LogMailer{Dir: dir}
+
+	err := m.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Verify your email",
+		Body:    "token: abc123",
+	})
+	if err != nil {
+		t.Fatalf("Send() returned error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one email file, got %v (err %v)", files, err)
+	}
+
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read email file: %v", err)
+	}
+	if !strings.Contains(string(content), "Subject: Verify your email") || !strings.Contains(string(content), "token: abc123") {
+		t.Fatalf("unexpected email content: %s", content)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("MAILER_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	if _, ok := NewFromEnv().(*SMTPMailer); !ok {
+		t.Fatal("expected SMTP mailer when MAILER_DRIVER=smtp")
+	}
+
+	t.Setenv("MAILER_DRIVER", "")
+	if _, ok := NewFromEnv().(*LogMailer); !ok {
+		t.Fatal("expected log mailer by default")
+	}
+}
+
+func TestSMTPMessageHeaders(t *testing.T) {
+	m := &SMTPMailer{From: "noreply@example.com"}
+	raw := string(m.buildMessage(Message{To: "bob@example.com", Subject: "Hi", Body: "line1\nline2"}))
+
+	if !strings.Contains(raw, "From: noreply@example.com\r\n") || !strings.Contains(raw, "Subject: Hi\r\n") {
+		t.Fatalf("missing headers in %q", raw)
+	}
+	if !strings.HasSuffix(raw, "line1\r\nline2") {
+		t.Fatalf("body not CRLF encoded: %q", raw)
+	}
+}
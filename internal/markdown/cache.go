@@ -0,0 +1,62 @@
+package markdown
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a fixed-size cache of rendered HTML keyed by the hash of the
+// source, evicting the least recently used entry when full
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used
+	entries map[[32]byte]*list.Element
+}
+
+type lruEntry struct {
+	key  [32]byte
+	html string
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), entries: map[[32]byte]*list.Element{}}
+}
+
+func (c *lru) get(key [32]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).html, true
+}
+
+func (c *lru) add(key [32]byte, html string) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, html: html})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
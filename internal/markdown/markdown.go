@@ -0,0 +1,104 @@
+// Package markdown renders Reddit-flavoured markdown in post and comment
+// bodies to sanitised HTML.
+//
+// On top of CommonMark it supports tables, ~~strikethrough~~, bare URLs,
+// >!spoilers!<, ^superscript and ^(longer superscript), and links u/name
+// and c/name mentions to the user and community pages.
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"log"
+	"regexp"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// Rendered bodies kept by the default renderer
+const defaultCacheSize = 4096
+
+// Renderer converts markdown to HTML. It is safe for concurrent use.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	cache  *lru
+}
+
+// New returns a Renderer that caches up to cacheSize rendered documents
+func New(cacheSize int) *Renderer {
+	md := goldmark.New(
+		goldmark.WithParser(newParser()),
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough,
+			extension.Linkify,
+			redditExtension{},
+		),
+	)
+
+	// Raw HTML is already escaped by goldmark; the policy is a second line
+	// of defence against anything that slips through, such as javascript: links
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^md-spoiler$`)).OnElements("span")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return &Renderer{md: md, policy: policy, cache: newLRU(cacheSize)}
+}
+
+// Render returns the sanitised HTML for a markdown document
+func (r *Renderer) Render(source string) string {
+	if source == "" {
+		return ""
+	}
+
+	key := keyOf(source)
+	if html, ok := r.cache.get(key); ok {
+		return html
+	}
+
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		// Rendering only fails if the writer does; fall back to escaped text
+		log.Printf("Failed to render markdown: %v", err)
+		return r.policy.Sanitize(source)
+	}
+	html := r.policy.Sanitize(buf.String())
+
+	r.cache.add(key, html)
+	return html
+}
+
+// keyOf is the cache key of a document
+func keyOf(source string) [32]byte {
+	return sha256.Sum256([]byte(source))
+}
+
+var defaultRenderer = sync.OnceValue(func() *Renderer {
+	return New(defaultCacheSize)
+})
+
+// Render renders markdown with a shared, cached Renderer
+func Render(source string) string {
+	return defaultRenderer().Render(source)
+}
+
+// newParser is goldmark's default parser, except that lines starting with
+// ">!" open a spoiler rather than a blockquote
+func newParser() parser.Parser {
+	blockParsers := parser.DefaultBlockParsers()
+	for i, p := range blockParsers {
+		if bp := p.Value.(parser.BlockParser); bytes.Equal(bp.Trigger(), []byte{'>'}) {
+			blockParsers[i].Value = spoilerAwareBlockquoteParser{bp}
+		}
+	}
+	return parser.NewParser(
+		parser.WithBlockParsers(blockParsers...),
+		parser.WithInlineParsers(parser.DefaultInlineParsers()...),
+		parser.WithParagraphTransformers(parser.DefaultParagraphTransformers()...),
+	)
+}
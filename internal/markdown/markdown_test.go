@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	r := New(16)
+
+	tests := []struct {
+		name, source, want string
+	}{
+		{"emphasis", "some **bold** text", "<p>some <strong>bold</strong> text</p>"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>"},
+		{"spoiler", "the ending >!everyone **lives**!< ok", `<p>the ending <span class="md-spoiler">everyone <strong>lives</strong></span> ok</p>`},
+		{"spoiler at line start", ">!twist!<", `<p><span class="md-spoiler">twist</span></p>`},
+		{"unclosed spoiler", "a >! b", "<p>a &gt;! b</p>"},
+		{"blockquote", "> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>"},
+		{"superscript", "2^10 and ^(two words)", "<p>2<sup>10</sup> and <sup>two words</sup></p>"},
+		{"user mention", "thanks u/alice!", `<p>thanks <a href="/u/alice" rel="nofollow">u/alice</a>!</p>`},
+		{"community mention", "see /c/golang", `<p>see <a href="/c/golang" rel="nofollow">/c/golang</a></p>`},
+		{"mention inside word", "menu/items and bu/bob", "<p>menu/items and bu/bob</p>"},
+		{"mention in code", "`u/alice`", "<p><code>u/alice</code></p>"},
+		{"mention in link label", "[u/alice](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener" target="_blank">u/alice</a></p>`},
+		{"bare URL", "go to https://example.com/u/alice", `<p>go to <a href="https://example.com/u/alice" rel="nofollow noopener" target="_blank">https://example.com/u/alice</a></p>`},
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |", "<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>"},
+		{"raw HTML", "<script>alert(1)</script><b onclick=x>hi</b>", ""},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.TrimSpace(r.Render(tt.source)); got != tt.want {
+				t.Errorf("Render(%q) =\n%s\nwant\n%s", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	r := New(2)
+
+	first := r.Render("*one*")
+	if again := r.Render("*one*"); again != first {
+		t.Errorf("cached render = %q, want %q", again, first)
+	}
+	r.Render("*two*")
+	r.Render("*three*")
+	if n := r.cache.len(); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+	if _, ok := r.cache.get(keyOf("*one*")); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := r.cache.get(keyOf("*three*")); !ok {
+		t.Error("newest entry is missing")
+	}
+}
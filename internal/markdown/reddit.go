@@ -0,0 +1,204 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindSpoiler     = ast.NewNodeKind("Spoiler")
+	kindSuperscript = ast.NewNodeKind("Superscript")
+)
+
+// spoiler is text hidden until clicked, written >!like this!<
+type spoiler struct {
+	ast.BaseInline
+}
+
+func (n *spoiler) Kind() ast.NodeKind { return kindSpoiler }
+
+func (n *spoiler) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// superscript is written ^word or ^(several words)
+type superscript struct {
+	ast.BaseInline
+}
+
+func (n *superscript) Kind() ast.NodeKind { return kindSuperscript }
+
+func (n *superscript) Dump(source []byte, level int) { ast.DumpHelper(n, source, level, nil, nil) }
+
+// spoilerDelimiters pairs ">!" openers with "!<" closers, so spoilers can
+// contain other formatting
+type spoilerDelimiters struct{}
+
+func (spoilerDelimiters) IsDelimiter(b byte) bool { return b == '>' || b == '!' }
+
+func (spoilerDelimiters) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == '>' && closer.Char == '!'
+}
+
+func (spoilerDelimiters) OnMatch(consumes int) ast.Node { return &spoiler{} }
+
+type spoilerParser struct{}
+
+func (spoilerParser) Trigger() []byte { return []byte{'>', '!'} }
+
+func (spoilerParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if len(line) < 2 {
+		return nil
+	}
+
+	var delim *parser.Delimiter
+	switch {
+	case line[0] == '>' && line[1] == '!':
+		delim = parser.NewDelimiter(true, false, 2, '>', spoilerDelimiters{})
+	case line[0] == '!' && line[1] == '<':
+		delim = parser.NewDelimiter(false, true, 2, '!', spoilerDelimiters{})
+	default:
+		return nil
+	}
+	delim.Segment = segment.WithStop(segment.Start + 2)
+	block.Advance(2)
+	pc.PushDelimiter(delim)
+	return delim
+}
+
+// spoilerAwareBlockquoteParser leaves lines starting with ">!" to the
+// paragraph parser so they become spoilers rather than blockquotes
+type spoilerAwareBlockquoteParser struct {
+	parser.BlockParser
+}
+
+func (p spoilerAwareBlockquoteParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	if bytes.HasPrefix(bytes.TrimLeft(line, " "), []byte(">!")) {
+		return nil, parser.NoChildren
+	}
+	return p.BlockParser.Open(parent, reader, pc)
+}
+
+type superscriptParser struct{}
+
+func (superscriptParser) Trigger() []byte { return []byte{'^'} }
+
+func (superscriptParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	var start, end, consumed int
+	if len(line) > 1 && line[1] == '(' {
+		closing := bytes.IndexByte(line[2:], ')')
+		if closing < 0 {
+			return nil
+		}
+		start, end, consumed = 2, 2+closing, 2+closing+1
+	} else {
+		end = 1
+		for end < len(line) && !util.IsSpace(line[end]) && line[end] != '^' {
+			end++
+		}
+		start, consumed = 1, end
+	}
+	if end <= start {
+		return nil
+	}
+
+	node := &superscript{}
+	node.AppendChild(node, ast.NewTextSegment(text.NewSegment(segment.Start+start, segment.Start+end)))
+	block.Advance(consumed)
+	return node
+}
+
+// Usernames and community names are 3 to 30 letters, digits, _ and -
+var mentionPattern = regexp.MustCompile(`^/?([uc])/([A-Za-z0-9_-]{3,30})`)
+
+type mentionParser struct{}
+
+// Inline parsers only run at punctuation and whitespace, so like goldmark's
+// linkify this triggers on the character before the mention. ' ' also
+// stands for any whitespace and the start of a line.
+func (mentionParser) Trigger() []byte { return []byte{' ', '*', '_', '(', '/'} }
+
+func (mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if pc.IsInLinkLabel() {
+		return nil
+	}
+
+	line, segment := block.PeekLine()
+	consumes := 0
+	switch {
+	case line[0] == '/':
+		// "/u/name", unless it's part of a path like "menu/u/name"
+		if before := block.PrecendingCharacter(); before == '/' || before == '_' || before == '-' || unicode.IsLetter(before) || unicode.IsDigit(before) {
+			return nil
+		}
+	case util.IsSpace(line[0]) || util.IsPunct(line[0]):
+		consumes = 1
+	}
+
+	m := mentionPattern.FindSubmatchIndex(line[consumes:])
+	if m == nil {
+		return nil
+	}
+	if end := consumes + m[1]; end < len(line) && isNameByte(line[end]) {
+		return nil
+	}
+
+	if consumes != 0 {
+		ast.MergeOrAppendTextSegment(parent, segment.WithStop(segment.Start+consumes))
+	}
+	name := line[consumes:]
+	link := ast.NewLink()
+	link.Destination = []byte("/" + string(name[m[2]:m[3]]) + "/" + string(name[m[4]:m[5]]))
+	link.AppendChild(link, ast.NewTextSegment(text.NewSegment(segment.Start+consumes, segment.Start+consumes+m[1])))
+	block.Advance(consumes + m[1])
+	return link
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || b == '-' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+type htmlRenderer struct{}
+
+func (htmlRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindSpoiler, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			w.WriteString(`<span class="md-spoiler">`)
+		} else {
+			w.WriteString("</span>")
+		}
+		return ast.WalkContinue, nil
+	})
+	reg.Register(kindSuperscript, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			w.WriteString("<sup>")
+		} else {
+			w.WriteString("</sup>")
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// redditExtension adds spoilers, superscript and mentions
+type redditExtension struct{}
+
+func (redditExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		util.Prioritized(spoilerParser{}, 500),
+		util.Prioritized(superscriptParser{}, 500),
+		util.Prioritized(mentionParser{}, 999), // After emphasis, which shares its triggers
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(htmlRenderer{}, 500),
+	))
+}
@@ -4,15 +4,19 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
 )
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
 // AuthMiddleware validates JWT tokens and protects routes
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -49,10 +53,19 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// Extract claims
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			// Make sure the session behind the token hasn't been revoked
+			sid, _ := claims["sid"].(string)
+			if !sessionActive(db, sid) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
+			}
+
 			// Set user information in context for use in handlers
 			c.Set("user_id", uint(claims["user_id"].(float64)))
 			c.Set("username", claims["username"].(string))
 			c.Set("email", claims["email"].(string))
+			c.Set("session_id", sid)
 
 			// Continue to next handler
 			c.Next()
@@ -66,7 +79,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 // OptionalAuthMiddleware extracts user info if token exists but doesn't block request
 // Useful for routes that should work for both authenticated and unauthenticated users
-func OptionalAuthMiddleware() gin.HandlerFunc {
+func OptionalAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -91,12 +104,28 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 
 		if err == nil {
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				c.Set("user_id", uint(claims["user_id"].(float64)))
-				c.Set("username", claims["username"].(string))
-				c.Set("email", claims["email"].(string))
+				if sid, _ := claims["sid"].(string); sessionActive(db, sid) {
+					c.Set("user_id", uint(claims["user_id"].(float64)))
+					c.Set("username", claims["username"].(string))
+					c.Set("email", claims["email"].(string))
+					c.Set("session_id", sid)
+				}
 			}
 		}
 
 		c.Next()
 	}
 }
+
+// sessionActive reports whether the session exists and is neither revoked nor expired
+func sessionActive(db *gorm.DB, sid string) bool {
+	if sid == "" {
+		return false
+	}
+
+	var count int64
+	db.Model(&models.Session{}).
+		Where("token_id = ? AND revoked_at IS NULL AND expires_at > ?", sid, time.Now()).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/emilythestrangee/reddit-clone/backend/internal/models"
+)
+
+// RequireVerifiedEmail blocks users whose email address is not verified.
+// It only takes effect when REQUIRE_VERIFIED_EMAIL=true, so posting stays
+// open by default. Must run after AuthMiddleware.
+func RequireVerifiedEmail(db *gorm.DB) gin.HandlerFunc {
+	enabled := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	return func(c *gin.Context) {
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := db.Select("id", "email_verified").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if !user.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before posting"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
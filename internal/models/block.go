@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Block hides one user's content from another and stops the blocked user
+// from interacting with the blocker
+type Block struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	BlockerID int       `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked" json:"blocker_id"`
+	BlockedID int       `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked;index" json:"blocked_id"`
+	Blocked   User      `gorm:"foreignKey:BlockedID" json:"blocked"`
+	CreatedAt time.Time `json:"created_at"`
+}
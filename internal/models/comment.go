@@ -3,17 +3,23 @@ package models
 import "time"
 
 type Comment struct {
-	ID              int       `gorm:"primaryKey" json:"id"`
-	Body            string    `gorm:"not null" json:"body"`
-	AuthorID        int       `json:"author_id"`
-	Author          string    `json:"author"`
-	User            User      `gorm:"foreignKey:AuthorID" json:"user"`
-	PostID          int       `json:"post_id"`
-	ParentCommentID *int      `json:"parent_comment_id,omitempty"`
-	Upvotes         int       `json:"upvotes"`
-	Downvotes       int       `json:"downvotes"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              int        `gorm:"primaryKey" json:"id"`
+	Body            string     `gorm:"not null" json:"body"`
+	AuthorID        *int       `json:"author_id"` // Nil once the author's account is purged
+	Author          string     `json:"author"`
+	User            *User      `gorm:"foreignKey:AuthorID" json:"user"`
+	PostID          int        `json:"post_id"`
+	ParentCommentID *int       `json:"parent_comment_id,omitempty"`
+	Upvotes         int        `json:"upvotes"`
+	Downvotes       int        `json:"downvotes"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	EditedAt        *time.Time `json:"edited_at,omitempty"` // Last change to the content by its author
+}
+
+// IsAuthor reports whether userID wrote the comment
+func (c *Comment) IsAuthor(userID int) bool {
+	return c.AuthorID != nil && *c.AuthorID == userID
 }
 
 type CreateCommentRequest struct {
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Community is a topic posts can be submitted to. Posts in an NSFW
+// community are treated as NSFW whatever their own flag says.
+type Community struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Description string    `json:"description"`
+	NSFW        bool      `gorm:"column:nsfw;not null;default:false" json:"nsfw"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Data export states
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportReady      = "ready"
+	DataExportFailed     = "failed"
+	DataExportExpired    = "expired"
+)
+
+// DataExport is a requested archive of a user's personal data
+type DataExport struct {
+	ID          int        `gorm:"primaryKey" json:"id"`
+	UserID      int        `gorm:"index;not null" json:"user_id"`
+	Status      string     `gorm:"index;not null" json:"status"`
+	FilePath    string     `json:"-"`
+	Size        int64      `json:"size,omitempty"` // Bytes
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // When the archive is deleted
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// HiddenPost keeps a post out of one user's feeds
+type HiddenPost struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_hidden_posts_user_post" json:"user_id"`
+	PostID    int       `gorm:"not null;uniqueIndex:idx_hidden_posts_user_post;index" json:"post_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PostView records when a user last opened a post, for their read history
+type PostView struct {
+	ID       int       `gorm:"primaryKey" json:"id"`
+	UserID   int       `gorm:"not null;uniqueIndex:idx_post_views_user_post;index:idx_post_views_user_viewed,priority:1" json:"user_id"`
+	PostID   int       `gorm:"not null;uniqueIndex:idx_post_views_user_post;index" json:"post_id"`
+	ViewedAt time.Time `gorm:"not null;index:idx_post_views_user_viewed,priority:2" json:"viewed_at"`
+}
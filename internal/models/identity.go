@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Identity providers
+const (
+	ProviderGoogle = "google"
+	ProviderApple  = "apple"
+)
+
+// UserIdentity links an external login provider account to a user. A user
+// can have one identity per provider.
+type UserIdentity struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_user_identities_user_provider" json:"user_id"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_user_identities_user_provider;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject" json:"-"` // Provider's user ID ("sub")
+	Email     string    `json:"email"`                                                              // Email reported by the provider when linked
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Link preview states
+const (
+	LinkPreviewPending  = "pending"
+	LinkPreviewFetching = "fetching"
+	LinkPreviewReady    = "ready"
+	LinkPreviewFailed   = "failed"
+)
+
+// LinkPreview is what a link post's target page says about itself, fetched
+// in the background after the post is created
+type LinkPreview struct {
+	PostID      int       `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Status      string    `gorm:"not null;default:pending;index" json:"status"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	ImageURL    string    `json:"-"` // Where the thumbnail was downloaded from
+	MediaID     *int      `json:"-"` // The downloaded thumbnail
+	Media       *Media    `gorm:"foreignKey:MediaID" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
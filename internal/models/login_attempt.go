@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for an account or IP address.
+// Key is prefixed with "account:" or "ip:".
+type LoginAttempt struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
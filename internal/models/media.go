@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Media kinds
+const (
+	MediaKindImage = "image"
+	MediaKindVideo = "video"
+)
+
+// Media processing states. Videos are stored as uploaded and start out ready.
+const (
+	MediaStatusPending    = "pending"
+	MediaStatusProcessing = "processing"
+	MediaStatusReady      = "ready"
+	MediaStatusFailed     = "failed"
+)
+
+// Media is an uploaded file, referenced by posts and avatars
+type Media struct {
+	ID          int            `gorm:"primaryKey" json:"id"`
+	UserID      int            `gorm:"not null;index" json:"-"`
+	Kind        string         `gorm:"not null" json:"kind"`
+	Key         string         `gorm:"not null;uniqueIndex" json:"-"` // Storage key
+	ContentType string         `gorm:"not null" json:"content_type"`
+	Size        int64          `json:"size"`
+	Status      string         `gorm:"not null;default:pending;index" json:"status"`
+	Width       int            `json:"width,omitempty"`
+	Height      int            `json:"height,omitempty"`
+	Hash        string         `gorm:"index" json:"-"` // Perceptual hash as 16 hex digits, for finding reposts
+	Variants    []MediaVariant `gorm:"foreignKey:MediaID" json:"-"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// MediaVariant is a resized copy of an uploaded image, such as a thumbnail
+type MediaVariant struct {
+	ID          int    `gorm:"primaryKey" json:"id"`
+	MediaID     int    `gorm:"not null;uniqueIndex:idx_media_variant" json:"-"`
+	Name        string `gorm:"not null;uniqueIndex:idx_media_variant" json:"name"`
+	Key         string `gorm:"not null;uniqueIndex" json:"-"`
+	ContentType string `gorm:"not null" json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// RecoveryCode is a hashed one-time code that can stand in for any second factor
+type RecoveryCode struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	UserID    int        `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Phone code purposes
+const (
+	PhoneCodePurposeVerification = "phone_verification"
+	PhoneCodePurposeLogin        = "login"
+)
+
+// PhoneCode is a short numeric code sent by SMS. Only its hash is stored.
+type PhoneCode struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	UserID    int        `gorm:"index;not null" json:"user_id"`
+	Phone     string     `gorm:"not null" json:"phone"`
+	Purpose   string     `gorm:"not null" json:"purpose"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	Attempts  int        `gorm:"default:0" json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey registered by a user. A user can have
+// several, e.g. one per device.
+type WebAuthnCredential struct {
+	ID              int        `gorm:"primaryKey" json:"id"`
+	UserID          int        `gorm:"index;not null" json:"user_id"`
+	Name            string     `gorm:"not null" json:"name"`
+	CredentialID    []byte     `gorm:"uniqueIndex;not null" json:"-"`
+	PublicKey       []byte     `gorm:"not null" json:"-"` // COSE encoded
+	AttestationType string     `json:"-"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `gorm:"default:0" json:"-"`
+	Transports      string     `json:"transports"`      // Comma separated, e.g. "internal,hybrid"
+	BackupEligible  bool       `json:"backup_eligible"` // Can be synced between devices
+	BackupState     bool       `json:"backed_up"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// WebAuthn ceremony purposes
+const (
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+	WebAuthnPurposeMFA          = "mfa"
+)
+
+// WebAuthnSession holds the challenge of a ceremony between its begin and
+// finish requests. It is deleted when the ceremony finishes.
+type WebAuthnSession struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	UserID    int       `gorm:"index" json:"user_id"` // Zero for passwordless login, where the user isn't known yet
+	Purpose   string    `gorm:"not null" json:"purpose"`
+	Data      string    `gorm:"type:text;not null" json:"-"` // JSON encoded webauthn.SessionData
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -2,23 +2,45 @@ package models
 
 import "time"
 
+// Post kinds
+const (
+	PostKindText    = "text"
+	PostKindLink    = "link"
+	PostKindImage   = "image"
+	PostKindVideo   = "video"
+	PostKindGallery = "gallery"
+)
+
 type Post struct {
-	ID          int       `gorm:"primaryKey" json:"id"`
-	Title       string    `gorm:"not null" json:"title"`
-	Body        string    `json:"body,omitempty"`
-	Content     string    `json:"content"`
-	Image       string    `json:"image"`
-	UserID      int       `json:"user_id"`
-	AuthorID    int       `json:"author_id"`
-	Author      string    `json:"author"`
-	CommunityID int       `json:"community_id"`
-	Community   string    `json:"community"`
-	Comments    int       `json:"comments"`
-	CreatedAt   time.Time `json:"created_at"`
-	User        User      `gorm:"foreignKey:UserID" json:"user"`
-	Upvotes     int       `gorm:"default:0" json:"upvotes"`
-	Downvotes   int       `gorm:"default:0" json:"downvotes"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int          `gorm:"primaryKey" json:"id"`
+	Title       string       `gorm:"not null" json:"title"`
+	Body        string       `json:"body,omitempty"`
+	Content     string       `json:"content"`
+	Image       string       `json:"image"` // Deprecated: first image of image posts, use Media
+	Kind        string       `gorm:"not null;default:text;index" json:"kind"`
+	URL         string       `json:"url,omitempty"`                 // Normalised target of link posts
+	Domain      string       `gorm:"index" json:"domain,omitempty"` // Link host without "www."
+	UserID      *int         `json:"user_id"`                       // Nil once the author's account is purged
+	AuthorID    *int         `json:"author_id"`                     // Nil once the author's account is purged
+	Author      string       `json:"author"`
+	CommunityID int          `json:"community_id"`
+	Community   string       `json:"community"`
+	Comments    int          `json:"comments"`
+	NSFW        bool         `gorm:"column:nsfw;not null;default:false;index" json:"nsfw"`
+	Spoiler     bool         `gorm:"not null;default:false" json:"spoiler"` // Clients blur the body and media until clicked
+	CreatedAt   time.Time    `json:"created_at"`
+	User        *User        `gorm:"foreignKey:UserID" json:"user"`
+	Media       []PostMedia  `gorm:"foreignKey:PostID" json:"media"`
+	Preview     *LinkPreview `gorm:"foreignKey:PostID" json:"preview,omitempty"` // Link posts only
+	Upvotes     int          `gorm:"default:0" json:"upvotes"`
+	Downvotes   int          `gorm:"default:0" json:"downvotes"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	EditedAt    *time.Time   `json:"edited_at,omitempty"` // Last change to the content by its author
+}
+
+// IsAuthor reports whether userID wrote the post
+func (p *Post) IsAuthor(userID int) bool {
+	return (p.AuthorID != nil && *p.AuthorID == userID) || (p.UserID != nil && *p.UserID == userID)
 }
 
 type CreatePostRequest struct {
@@ -26,3 +48,15 @@ type CreatePostRequest struct {
 	Body        string `json:"body"`
 	CommunityID int    `json:"community_id"`
 }
+
+// PostMedia is one image or video attached to a post, in display order
+type PostMedia struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	PostID    int       `gorm:"not null;index" json:"-"`
+	MediaID   *int      `gorm:"index" json:"media_id,omitempty"` // Set for uploads, nil for external URLs
+	Position  int       `gorm:"not null" json:"position"`
+	URL       string    `gorm:"not null" json:"url"`
+	Caption   string    `json:"caption,omitempty"`
+	Upload    *Media    `gorm:"foreignKey:MediaID" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Feed sort orders
+const (
+	FeedSortHot = "hot"
+	FeedSortNew = "new"
+	FeedSortTop = "top"
+)
+
+// Comment sort orders
+const (
+	CommentSortTop = "top"
+	CommentSortNew = "new"
+	CommentSortOld = "old"
+)
+
+// EmailNotificationPreferences are the optional emails a user can turn off.
+// Security and account emails are always sent.
+type EmailNotificationPreferences struct {
+	PostReplies    bool `gorm:"not null" json:"post_replies"`
+	CommentReplies bool `gorm:"not null" json:"comment_replies"`
+	Mentions       bool `gorm:"not null" json:"mentions"`
+	NewFollowers   bool `gorm:"not null" json:"new_followers"`
+}
+
+// UserPreferences holds a user's settings. Users without a row get
+// DefaultUserPreferences. Booleans never have a true column default,
+// otherwise GORM would write true for false values on insert. Columns added
+// after the table was created default to false so existing rows migrate.
+type UserPreferences struct {
+	UserID             int                          `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	FeedSort           string                       `gorm:"not null;default:new" json:"feed_sort"`
+	CommentSort        string                       `gorm:"not null;default:new" json:"comment_sort"`
+	ShowNSFW           bool                         `gorm:"column:show_nsfw;not null" json:"show_nsfw"`
+	BlurSpoilers       bool                         `gorm:"not null" json:"blur_spoilers"`
+	EmailNotifications EmailNotificationPreferences `gorm:"embedded;embeddedPrefix:email_" json:"email_notifications"`
+	PrivateProfile     bool                         `gorm:"not null" json:"private_profile"` // Only the owner sees more than the username and avatar
+	HideFollowers      bool                         `gorm:"not null;default:false" json:"hide_followers"`
+	HideFollowing      bool                         `gorm:"not null;default:false" json:"hide_following"`
+	HideVotes          bool                         `gorm:"not null;default:false" json:"hide_votes"`       // Hides GET /users/:id/votes from others
+	HideFromSearch     bool                         `gorm:"not null;default:false" json:"hide_from_search"` // Leaves the user out of GET /users?q=
+	PauseHistory       bool                         `gorm:"not null;default:false" json:"pause_history"`    // Stop recording read history
+	Language           string                       `gorm:"not null;default:en" json:"language"`            // BCP 47 tag
+	Timezone           string                       `gorm:"not null;default:UTC" json:"timezone"`           // IANA name
+	UpdatedAt          time.Time                    `json:"updated_at"`
+}
+
+// DefaultUserPreferences returns the settings used until a user changes them
+func DefaultUserPreferences(userID int) UserPreferences {
+	return UserPreferences{
+		UserID:       userID,
+		FeedSort:     FeedSortNew,
+		CommentSort:  CommentSortNew,
+		BlurSpoilers: true,
+		EmailNotifications: EmailNotificationPreferences{
+			PostReplies:    true,
+			CommentReplies: true,
+			Mentions:       true,
+		},
+		Language: "en",
+		Timezone: "UTC",
+	}
+}
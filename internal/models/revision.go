@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Revision is one version of a post or comment. The first revision is the
+// original, recorded when the content is first edited.
+type Revision struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	PostID    *int      `gorm:"index" json:"post_id,omitempty"`    // Set for post revisions
+	CommentID *int      `gorm:"index" json:"comment_id,omitempty"` // Set for comment revisions
+	EditorID  int       `gorm:"index" json:"editor_id"`
+	Title     string    `json:"title,omitempty"` // Posts only
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
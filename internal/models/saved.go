@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Types of saved item
+const (
+	SavedTypePost    = "post"
+	SavedTypeComment = "comment"
+)
+
+// SavedItem is a post or comment a user saved for later
+type SavedItem struct {
+	ID           int       `gorm:"primaryKey" json:"id"`
+	UserID       int       `gorm:"not null;uniqueIndex:idx_saved_items_target" json:"user_id"`
+	PostID       int       `gorm:"not null;default:0;uniqueIndex:idx_saved_items_target" json:"post_id"`    // non-zero for saved posts
+	CommentID    int       `gorm:"not null;default:0;uniqueIndex:idx_saved_items_target" json:"comment_id"` // non-zero for saved comments
+	CollectionID *int      `gorm:"index" json:"collection_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SavedCollection is a named, private group of saved items
+type SavedCollection struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_saved_collections_name" json:"user_id"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_saved_collections_name" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
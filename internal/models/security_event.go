@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Security event types
+const (
+	SecurityEventPasswordChanged      = "password_changed"
+	SecurityEventPasswordReset        = "password_reset"
+	SecurityEventEmailChangeRequested = "email_change_requested"
+	SecurityEventEmailChanged         = "email_changed"
+	SecurityEventUsernameChanged      = "username_changed"
+	SecurityEventMFAEnabled           = "mfa_enabled"
+	SecurityEventMFADisabled          = "mfa_disabled"
+	SecurityEventRecoveryCodesReset   = "recovery_codes_regenerated"
+	SecurityEventRecoveryCodeUsed     = "recovery_code_used"
+	SecurityEventPhoneVerified        = "phone_verified"
+	SecurityEventPhoneRemoved         = "phone_removed"
+	SecurityEventIdentityLinked       = "identity_linked"
+	SecurityEventIdentityUnlinked     = "identity_unlinked"
+	SecurityEventPasskeyAdded         = "passkey_added"
+	SecurityEventPasskeyRemoved       = "passkey_removed"
+	SecurityEventPasskeyCloned        = "passkey_clone_detected"
+	SecurityEventDeletionRequested    = "account_deletion_requested"
+	SecurityEventDeletionCancelled    = "account_deletion_cancelled"
+	SecurityEventDataExportRequested  = "data_export_requested"
+)
+
+// SecurityEvent is an entry in a user's account security history
+type SecurityEvent struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	UserID    int       `gorm:"index;not null" json:"user_id"`
+	Event     string    `gorm:"not null" json:"event"`
+	Details   string    `json:"details,omitempty"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReservedUsername keeps a username from being claimed by someone else,
+// e.g. for a while after its owner renamed their account
+type ReservedUsername struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	Username  string     `gorm:"uniqueIndex;not null" json:"username"`
+	UserID    int        `gorm:"index" json:"user_id"` // Previous owner, who may reclaim it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil means reserved forever
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is an issued login. Access tokens carry the session's TokenID in
+// their "sid" claim so they can be revoked before they expire.
+type Session struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	UserID    int        `gorm:"index;not null" json:"user_id"`
+	TokenID   string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserAgent string     `json:"user_agent"`
+	IPAddress string     `json:"ip_address"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
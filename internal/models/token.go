@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Token purposes
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// UserToken is a single-use token emailed to a user. Only the SHA-256 hash
+// of the token is stored.
+type UserToken struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	UserID    int        `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"index;not null" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	Email     string     `json:"email"` // Address the token was sent to
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
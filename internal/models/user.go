@@ -2,18 +2,52 @@ package models
 
 import "time"
 
+// User roles. Moderators and admins can see edit history of any post.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID       int    `gorm:"primaryKey" json:"id"`
 	Username string `gorm:"unique;not null" json:"username"`
-	Email    string `gorm:"unique;not null" json:"email"`
-	Password string `gorm:"not null" json:"-"` // For email/password auth
+	Email    string `gorm:"unique;not null" json:"-"` // Private, only returned to the owner
+	Password string `gorm:"not null" json:"-"`        // For email/password auth
 	Bio      string `json:"bio"`
 	Avatar   string `json:"avatar"` // Stores avatar ID (1-6) or URL
 
+	AvatarMediaID *int `json:"-"` // Set when the avatar is an upload
+
+	Role string `gorm:"not null;default:user" json:"-"` // RoleUser, RoleModerator or RoleAdmin
+
+	// Email verification
+	EmailVerified   bool       `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	UsernameChangedAt *time.Time `json:"-"` // Used to enforce the rename cooldown
+
+	// Two-factor authentication
+	TOTPSecret   string `json:"-"`                                 // Base32 secret, set during enrolment
+	TOTPEnabled  bool   `gorm:"default:false" json:"totp_enabled"` // True once enrolment is confirmed
+	TOTPLastStep int64  `json:"-"`                                 // Last accepted time step, prevents code reuse
+
+	// Phone number (E.164) for SMS verification and SMS 2FA
+	Phone         string `gorm:"index;uniqueIndex:idx_users_verified_phone,where:phone_verified" json:"-"` // Verified on at most one account
+	PhoneVerified bool   `gorm:"default:false" json:"phone_verified"`
+	SMSMFAEnabled bool   `gorm:"default:false" json:"sms_mfa_enabled"`
+
 	// OAuth fields
+	// Deprecated: GoogleID and AppleID are only kept so existing links can be
+	// copied into user_identities; use UserIdentity instead.
 	GoogleID     string `gorm:"index" json:"-"` // Google user ID
 	AppleID      string `gorm:"index" json:"-"` // Apple user ID
-	AuthProvider string `json:"auth_provider"`  // "email", "google", "apple"
+	AuthProvider string `json:"auth_provider"`  // How the account was created: "email", "google", "apple"
+
+	// Account deletion. The account is purged once DeletionScheduledFor has
+	// passed unless the user logs in again before then.
+	DeletionRequestedAt  *time.Time `json:"-"`
+	DeletionScheduledFor *time.Time `gorm:"index" json:"-"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
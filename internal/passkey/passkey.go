@@ -0,0 +1,194 @@
+package passkey
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	// ErrCloned is returned when an assertion's signature counter went
+	// backwards, which means the credential's key may have been copied
+	ErrCloned = errors.New("passkey signature counter did not increase, the credential may be cloned")
+
+	ErrInvalidUserHandle = errors.New("invalid user handle")
+)
+
+// Config describes the relying party (this site) to authenticators
+type Config struct {
+	RPID    string   // Domain the credentials are scoped to, e.g. "example.com"
+	RPName  string   // Name shown by the browser during the ceremony
+	Origins []string // Full origins allowed to run ceremonies, e.g. "https://example.com"
+}
+
+// Service runs WebAuthn registration and assertion ceremonies
+type Service struct {
+	webauthn *webauthn.WebAuthn
+}
+
+func New(cfg Config) (*Service, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:                  cfg.RPID,
+		RPDisplayName:         cfg.RPName,
+		RPOrigins:             cfg.Origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Service{webauthn: w}, nil
+}
+
+// NewFromEnv builds a service from WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and
+// WEBAUTHN_ORIGINS (comma separated). Unset values are derived from APP_URL.
+func NewFromEnv() (*Service, error) {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+
+	cfg := Config{
+		RPID:   os.Getenv("WEBAUTHN_RP_ID"),
+		RPName: os.Getenv("WEBAUTHN_RP_NAME"),
+	}
+	if cfg.RPID == "" {
+		u, err := url.Parse(appURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid APP_URL: %w", err)
+		}
+		cfg.RPID = u.Hostname()
+	}
+	if cfg.RPName == "" {
+		cfg.RPName = "Reddit Clone"
+	}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.Origins = append(cfg.Origins, origin)
+		}
+	}
+	if len(cfg.Origins) == 0 {
+		cfg.Origins = []string{strings.TrimRight(appURL, "/")}
+	}
+
+	return New(cfg)
+}
+
+// User adapts an account and its stored credentials to webauthn.User
+type User struct {
+	ID          int
+	Name        string
+	Credentials []webauthn.Credential
+}
+
+// UserHandle is the opaque user ID stored on the authenticator
+func UserHandle(userID int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userID))
+	return b
+}
+
+// UserIDFromHandle reverses UserHandle
+func UserIDFromHandle(handle []byte) (int, error) {
+	if len(handle) != 8 {
+		return 0, ErrInvalidUserHandle
+	}
+	id := binary.BigEndian.Uint64(handle)
+	if id == 0 || id > uint64(^uint(0)>>1) {
+		return 0, ErrInvalidUserHandle
+	}
+	return int(id), nil
+}
+
+func (u *User) WebAuthnID() []byte                         { return UserHandle(u.ID) }
+func (u *User) WebAuthnName() string                       { return u.Name }
+func (u *User) WebAuthnDisplayName() string                { return u.Name }
+func (u *User) WebAuthnCredentials() []webauthn.Credential { return u.Credentials }
+
+// BeginRegistration starts creating a discoverable credential for the user.
+// Credentials the user already has are excluded so an authenticator can't
+// be registered twice.
+func (s *Service) BeginRegistration(user *User) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	return s.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.Credentials).CredentialDescriptors()),
+	)
+}
+
+// FinishRegistration validates the authenticator's attestation response
+// (the JSON produced by navigator.credentials.create) and returns the new credential
+func (s *Service) FinishRegistration(user *User, session webauthn.SessionData, response []byte) (*webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+	return s.webauthn.CreateCredential(user, session, parsed)
+}
+
+// BeginLogin starts an assertion limited to the user's own credentials,
+// which is what the second-factor step uses
+func (s *Service) BeginLogin(user *User) (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	return s.webauthn.BeginLogin(user)
+}
+
+// FinishLogin validates an assertion for a known user and returns the
+// credential with its updated signature counter
+func (s *Service) FinishLogin(user *User, session webauthn.SessionData, response []byte) (*webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webauthn.ValidateLogin(user, session, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return credential, ErrCloned
+	}
+	return credential, nil
+}
+
+// BeginDiscoverableLogin starts a passwordless login where the
+// authenticator picks the account. User verification (PIN, biometrics) is
+// required because the passkey is the only factor.
+func (s *Service) BeginDiscoverableLogin() (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	return s.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+}
+
+// FinishDiscoverableLogin validates a passwordless assertion. lookup loads
+// the user identified by the authenticator's user handle.
+func (s *Service) FinishDiscoverableLogin(session webauthn.SessionData, response []byte, lookup func(userID int) (*User, error)) (*User, *webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// user is kept even when validation fails so callers can count the
+	// failure against the account
+	var user *User
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := UserIDFromHandle(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		if user, err = lookup(userID); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	_, credential, err := s.webauthn.ValidatePasskeyLogin(handler, session, parsed)
+	if err != nil {
+		return user, nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return user, credential, ErrCloned
+	}
+	return user, credential, nil
+}
@@ -0,0 +1,279 @@
+package passkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is a minimal in-memory platform authenticator holding a
+// single P-256 credential and answering with "none" attestation
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{key: key, credentialID: id, origin: testOrigin}
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, typ, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// create answers navigator.credentials.create for the given options
+func (a *softAuthenticator) create(t *testing.T, challenge string, userHandle []byte) []byte {
+	t.Helper()
+	a.userHandle = userHandle
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.PublicKey.X.FillBytes(x)
+	a.key.PublicKey.Y.FillBytes(y)
+	coseKey, err := webauthncbor.Marshal(map[int]interface{}{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":    b64.EncodeToString(a.credentialID),
+		"rawId": b64.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", challenge)),
+			"attestationObject": b64.EncodeToString(attestation),
+			"transports":        []string{"internal"},
+		},
+	})
+	return body
+}
+
+// get answers navigator.credentials.get, bumping the signature counter
+func (a *softAuthenticator) get(t *testing.T, challenge string) []byte {
+	t.Helper()
+	a.signCount++
+
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":    b64.EncodeToString(a.credentialID),
+		"rawId": b64.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    b64.EncodeToString(clientData),
+			"authenticatorData": b64.EncodeToString(authData),
+			"signature":         b64.EncodeToString(signature),
+			"userHandle":        b64.EncodeToString(a.userHandle),
+		},
+	})
+	return body
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	s, err := New(Config{RPID: testRPID, RPName: "Test", Origins: []string{testOrigin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// register runs a full registration ceremony and returns the stored credential
+func register(t *testing.T, s *Service, user *User, auth *softAuthenticator) webauthn.Credential {
+	t.Helper()
+	creation, session, err := s.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error: %v", err)
+	}
+	credential, err := s.FinishRegistration(user, *session, auth.create(t, session.Challenge, creation.Response.User.ID.(protocol.URLEncodedBase64)))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error: %v", err)
+	}
+	return *credential
+}
+
+func TestUserHandleRoundTrip(t *testing.T) {
+	id, err := UserIDFromHandle(UserHandle(42))
+	if err != nil || id != 42 {
+		t.Fatalf("UserIDFromHandle(UserHandle(42)) = %d, %v", id, err)
+	}
+	for _, handle := range [][]byte{nil, {1, 2, 3}, make([]byte, 8)} {
+		if _, err := UserIDFromHandle(handle); !errors.Is(err, ErrInvalidUserHandle) {
+			t.Errorf("UserIDFromHandle(%v) error = %v, want ErrInvalidUserHandle", handle, err)
+		}
+	}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	s := newTestService(t)
+	auth := newSoftAuthenticator(t)
+	user := &User{ID: 7, Name: "alice"}
+
+	credential := register(t, s, user, auth)
+	if string(credential.ID) != string(auth.credentialID) {
+		t.Fatal("registered credential ID doesn't match the authenticator's")
+	}
+	if credential.AttestationType != "none" {
+		t.Errorf("AttestationType = %q, want none", credential.AttestationType)
+	}
+	user.Credentials = []webauthn.Credential{credential}
+
+	for i := 1; i <= 2; i++ {
+		_, session, err := s.BeginLogin(user)
+		if err != nil {
+			t.Fatalf("BeginLogin() error: %v", err)
+		}
+		got, err := s.FinishLogin(user, *session, auth.get(t, session.Challenge))
+		if err != nil {
+			t.Fatalf("FinishLogin() #%d error: %v", i, err)
+		}
+		if got.Authenticator.SignCount != uint32(i) {
+			t.Errorf("SignCount = %d, want %d", got.Authenticator.SignCount, i)
+		}
+		user.Credentials[0] = *got
+	}
+}
+
+func TestFinishLoginRejectsReplayedChallenge(t *testing.T) {
+	s := newTestService(t)
+	auth := newSoftAuthenticator(t)
+	user := &User{ID: 7, Name: "alice"}
+	user.Credentials = []webauthn.Credential{register(t, s, user, auth)}
+
+	_, first, _ := s.BeginLogin(user)
+	_, second, _ := s.BeginLogin(user)
+	if _, err := s.FinishLogin(user, *second, auth.get(t, first.Challenge)); err == nil {
+		t.Fatal("expected an assertion for another challenge to be rejected")
+	}
+}
+
+func TestFinishLoginRejectsWrongOrigin(t *testing.T) {
+	s := newTestService(t)
+	auth := newSoftAuthenticator(t)
+	user := &User{ID: 7, Name: "alice"}
+	user.Credentials = []webauthn.Credential{register(t, s, user, auth)}
+
+	auth.origin = "https://evil.example.net"
+	_, session, _ := s.BeginLogin(user)
+	if _, err := s.FinishLogin(user, *session, auth.get(t, session.Challenge)); err == nil {
+		t.Fatal("expected an assertion from another origin to be rejected")
+	}
+}
+
+func TestFinishLoginDetectsClonedCredential(t *testing.T) {
+	s := newTestService(t)
+	auth := newSoftAuthenticator(t)
+	user := &User{ID: 7, Name: "alice"}
+	credential := register(t, s, user, auth)
+
+	// The server has already seen a higher counter than the authenticator will send
+	credential.Authenticator.SignCount = 10
+	user.Credentials = []webauthn.Credential{credential}
+
+	_, session, _ := s.BeginLogin(user)
+	if _, err := s.FinishLogin(user, *session, auth.get(t, session.Challenge)); !errors.Is(err, ErrCloned) {
+		t.Fatalf("FinishLogin() error = %v, want ErrCloned", err)
+	}
+}
+
+func TestDiscoverableLogin(t *testing.T) {
+	s := newTestService(t)
+	auth := newSoftAuthenticator(t)
+	alice := &User{ID: 7, Name: "alice"}
+	alice.Credentials = []webauthn.Credential{register(t, s, alice, auth)}
+
+	lookup := func(userID int) (*User, error) {
+		if userID != alice.ID {
+			return nil, errors.New("not found")
+		}
+		return alice, nil
+	}
+
+	_, session, err := s.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatalf("BeginDiscoverableLogin() error: %v", err)
+	}
+	user, credential, err := s.FinishDiscoverableLogin(*session, auth.get(t, session.Challenge), lookup)
+	if err != nil {
+		t.Fatalf("FinishDiscoverableLogin() error: %v", err)
+	}
+	if user.ID != alice.ID || credential.Authenticator.SignCount != 1 {
+		t.Fatalf("got user %d sign count %d", user.ID, credential.Authenticator.SignCount)
+	}
+
+	// A credential whose user handle points at an unknown account fails
+	auth.userHandle = UserHandle(99)
+	_, session, _ = s.BeginDiscoverableLogin()
+	if _, _, err := s.FinishDiscoverableLogin(*session, auth.get(t, session.Challenge), lookup); err == nil {
+		t.Fatal("expected login with an unknown user handle to fail")
+	}
+}
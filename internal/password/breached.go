@@ -0,0 +1,96 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The bundled list holds upper-case SHA-1 hashes of well-known breached
+// passwords, one per line. Lines may carry a ":count" suffix so files in
+// the Have I Been Pwned format can be used as well.
+//
+//go:embed breached.txt
+var bundledBreached string
+
+// BreachedList answers k-anonymity range queries: hashes are grouped by
+// their first five hex characters, so a lookup only ever needs the prefix
+// of the password's hash, as with the Pwned Passwords range API.
+type BreachedList struct {
+	ranges map[string]map[string]bool // prefix -> set of 35-character suffixes
+}
+
+// LoadBreachedList reads SHA-1 hashes (optionally "HASH:COUNT"), one per line
+func LoadBreachedList(r io.Reader) (*BreachedList, error) {
+	list := &BreachedList{ranges: map[string]map[string]bool{}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != 40 {
+			return nil, fmt.Errorf("invalid SHA-1 hash %q", hash)
+		}
+		if list.ranges[hash[:5]] == nil {
+			list.ranges[hash[:5]] = map[string]bool{}
+		}
+		list.ranges[hash[:5]][hash[5:]] = true
+	}
+	return list, scanner.Err()
+}
+
+var (
+	defaultListOnce sync.Once
+	defaultList     *BreachedList
+	defaultListErr  error
+)
+
+// DefaultBreachedList returns the list from PASSWORD_BREACHED_FILE when it
+// is set, for example a full Pwned Passwords download, and the bundled list otherwise
+func DefaultBreachedList() (*BreachedList, error) {
+	defaultListOnce.Do(func() {
+		if path := os.Getenv("PASSWORD_BREACHED_FILE"); path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				defaultListErr = err
+				return
+			}
+			defer f.Close()
+			defaultList, defaultListErr = LoadBreachedList(f)
+			return
+		}
+		defaultList, defaultListErr = LoadBreachedList(strings.NewReader(bundledBreached))
+	})
+	return defaultList, defaultListErr
+}
+
+// Range returns the hash suffixes sharing a five-character prefix
+func (l *BreachedList) Range(prefix string) []string {
+	suffixes := make([]string, 0, len(l.ranges[strings.ToUpper(prefix)]))
+	for suffix := range l.ranges[strings.ToUpper(prefix)] {
+		suffixes = append(suffixes, suffix)
+	}
+	return suffixes
+}
+
+// Contains reports whether the password appears in the list
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	for _, suffix := range l.Range(hash[:5]) {
+		if suffix == hash[5:] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported hashing algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	// ErrMismatch is returned when a password doesn't match its hash
+	ErrMismatch = errors.New("password does not match")
+	// ErrUnknownHash is returned for hashes in an unrecognised format
+	ErrUnknownHash = errors.New("unknown password hash format")
+)
+
+// Argon2Params are the argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // In KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation of 64 MiB, 3 passes
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher creates password hashes with one algorithm and cost, and verifies
+// hashes made with any supported algorithm. Hashes are self-describing
+// (PHC strings for argon2id, modular crypt format for bcrypt), so changing
+// the settings only affects new hashes, and NeedsRehash tells which stored
+// hashes are out of date.
+type Hasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultHasher uses argon2id with DefaultArgon2Params
+var DefaultHasher = Hasher{
+	Algorithm:  AlgorithmArgon2id,
+	Argon2:     DefaultArgon2Params,
+	BcryptCost: bcrypt.DefaultCost,
+}
+
+// HasherFromEnv reads PASSWORD_HASH_ALGORITHM, ARGON2_MEMORY (KiB),
+// ARGON2_ITERATIONS, ARGON2_PARALLELISM and BCRYPT_COST, falling back to
+// DefaultHasher
+func HasherFromEnv() Hasher {
+	h := DefaultHasher
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm == AlgorithmBcrypt || algorithm == AlgorithmArgon2id {
+		h.Algorithm = algorithm
+	}
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil && v >= 8 {
+		h.Argon2.Memory = uint32(v)
+	}
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_ITERATIONS"), 10, 32); err == nil && v > 0 {
+		h.Argon2.Iterations = uint32(v)
+	}
+	if v, err := strconv.ParseUint(os.Getenv("ARGON2_PARALLELISM"), 10, 8); err == nil && v > 0 {
+		h.Argon2.Parallelism = uint8(v)
+	}
+	if v, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil && v >= bcrypt.MinCost && v <= bcrypt.MaxCost {
+		h.BcryptCost = v
+	}
+	return h
+}
+
+// Hash hashes a password with the configured algorithm
+func (h Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, h.Argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Argon2.Memory, h.Argon2.Iterations, h.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks a password against a hash made with any supported
+// algorithm. It returns ErrMismatch when the password is wrong.
+func (h Hasher) Verify(password, encoded string) error {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+	return ErrUnknownHash
+}
+
+// NeedsRehash reports whether a hash uses a different algorithm or weaker
+// parameters than the hasher's, so it should be replaced the next time the
+// plain-text password is available
+func (h Hasher) NeedsRehash(encoded string) bool {
+	if isBcrypt(encoded) {
+		if h.Algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost < h.BcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil || h.Algorithm != AlgorithmArgon2id {
+		return true
+	}
+	return params.Memory < h.Argon2.Memory ||
+		params.Iterations < h.Argon2.Iterations ||
+		params.Parallelism != h.Argon2.Parallelism ||
+		uint32(len(salt)) < h.Argon2.SaltLength ||
+		uint32(len(key)) < h.Argon2.KeyLength
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// decodeArgon2id parses "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>"
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
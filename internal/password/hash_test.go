@@ -0,0 +1,92 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters so the tests run quickly
+var testArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	h := Hasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash() error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected PHC string %q", hash)
+	}
+
+	if err := h.Verify("correct horse", hash); err != nil {
+		t.Errorf("Verify() with the right password: %v", err)
+	}
+	if err := h.Verify("wrong horse", hash); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify() with the wrong password = %v, expected ErrMismatch", err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("a hash made with the current parameters should not need rehashing")
+	}
+
+	if other, _ := h.Hash("correct horse"); other == hash {
+		t.Error("expected a fresh salt for every hash")
+	}
+}
+
+func TestVerifyLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter22"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := Hasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}
+	if err := h.Verify("hunter22", string(legacy)); err != nil {
+		t.Errorf("Verify() with a bcrypt hash: %v", err)
+	}
+	if err := h.Verify("hunter23", string(legacy)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify() with the wrong password = %v, expected ErrMismatch", err)
+	}
+	if !h.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash should need rehashing when argon2id is configured")
+	}
+
+	bcryptHasher := Hasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}
+	if !bcryptHasher.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash below the configured cost should need rehashing")
+	}
+	bcryptHasher.BcryptCost = bcrypt.MinCost
+	if bcryptHasher.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash at the configured cost should not need rehashing")
+	}
+}
+
+func TestNeedsRehashWhenParametersIncrease(t *testing.T) {
+	old := Hasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}
+	hash, err := old.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := old
+	stronger.Argon2.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Error("expected a rehash after raising the iterations")
+	}
+	// Old hashes still verify after the upgrade
+	if err := stronger.Verify("correct horse", hash); err != nil {
+		t.Errorf("Verify() after changing parameters: %v", err)
+	}
+}
+
+func TestVerifyRejectsUnknownHashes(t *testing.T) {
+	h := Hasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}
+	for _, hash := range []string{"", "plaintext", "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=64,t=1$c2FsdA$a2V5"} {
+		if err := h.Verify("anything", hash); err == nil {
+			t.Errorf("Verify(%q) succeeded", hash)
+		}
+	}
+}
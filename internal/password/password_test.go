@@ -0,0 +1,106 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func violationCodes(violations []Violation) map[string]bool {
+	codes := map[string]bool{}
+	for _, v := range violations {
+		codes[v.Code] = true
+	}
+	return codes
+}
+
+func TestScore(t *testing.T) {
+	weak := []string{"password", "Password1", "qwerty123", "aaaaaaaaaaaa", "abcdefgh", "12345678", "P@ssw0rd", "Summer2024"}
+	for _, pw := range weak {
+		if s := Score(pw); s > 1 {
+			t.Errorf("Score(%q) = %d, expected 0 or 1", pw, s)
+		}
+	}
+
+	strong := []string{"correct horse battery staple", "vT7#qLm2$wXz9!", "glacier-mango-tuxedo-89"}
+	for _, pw := range strong {
+		if s := Score(pw); s < 3 {
+			t.Errorf("Score(%q) = %d, expected at least 3", pw, s)
+		}
+	}
+}
+
+func TestScoreUsesUserInputs(t *testing.T) {
+	pw := "zorblaxian"
+	if without, with := Score(pw), Score(pw, "Zorblaxian", "someone@example.com"); with >= without {
+		t.Errorf("expected the username to lower the score, got %d without and %d with", without, with)
+	}
+}
+
+func TestValidateReportsEveryViolation(t *testing.T) {
+	p := Policy{MinLength: 12, MaxLength: 72, MinScore: 3, CheckBreached: true}
+
+	codes := violationCodes(p.Validate("alice123", "alice", "alice@example.com"))
+	for _, want := range []string{ViolationTooShort, ViolationTooWeak, ViolationPersonalInfo} {
+		if !codes[want] {
+			t.Errorf("expected violation %q, got %v", want, codes)
+		}
+	}
+
+	if codes := violationCodes(p.Validate(strings.Repeat("x9!", 30))); !codes[ViolationTooLong] {
+		t.Errorf("expected %q for a 90 byte password, got %v", ViolationTooLong, codes)
+	}
+
+	if v := p.Validate("glacier-mango-tuxedo-89", "alice", "alice@example.com"); v != nil {
+		t.Errorf("expected a strong password to pass, got %v", v)
+	}
+}
+
+func TestValidatePersonalInfoUsesEmailLocalPart(t *testing.T) {
+	p := Policy{MinLength: 1}
+	if codes := violationCodes(p.Validate("my-JSMITH-pass", "", "jsmith@example.com")); !codes[ViolationPersonalInfo] {
+		t.Errorf("expected the email local part to be detected, got %v", codes)
+	}
+	if codes := violationCodes(p.Validate("example-rocks", "", "jsmith@example.com")); codes[ViolationPersonalInfo] {
+		t.Error("the email domain alone should not count as personal info")
+	}
+}
+
+func TestBreachedList(t *testing.T) {
+	list, err := LoadBreachedList(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8",     // "password"
+		"7c4a8d09ca3762af61e59520943dc26494f8941b:123", // "123456", lower case with a count
+	}, "\n")))
+	if err != nil {
+		t.Fatalf("LoadBreachedList() error: %v", err)
+	}
+
+	for _, pw := range []string{"password", "123456"} {
+		if !list.Contains(pw) {
+			t.Errorf("expected %q to be breached", pw)
+		}
+	}
+	if list.Contains("glacier-mango-tuxedo-89") {
+		t.Error("did not expect an unlisted password to be breached")
+	}
+
+	if got := list.Range("5baa6"); len(got) != 1 || got[0] != "1E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+		t.Errorf("Range(5baa6) = %v", got)
+	}
+
+	if _, err := LoadBreachedList(strings.NewReader("not-a-hash")); err == nil {
+		t.Error("expected an invalid line to be rejected")
+	}
+}
+
+func TestBundledBreachedList(t *testing.T) {
+	list, err := DefaultBreachedList()
+	if err != nil {
+		t.Fatalf("DefaultBreachedList() error: %v", err)
+	}
+	for _, pw := range []string{"password", "Password", "qwerty123", "iloveyou!"} {
+		if !list.Contains(pw) {
+			t.Errorf("expected %q in the bundled list", pw)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package password
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation codes
+const (
+	ViolationTooShort     = "too_short"
+	ViolationTooLong      = "too_long"
+	ViolationTooWeak      = "too_weak"
+	ViolationPersonalInfo = "contains_personal_info"
+	ViolationBreached     = "breached"
+)
+
+// Violation is one reason a password was rejected
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Policy is the set of rules new passwords must follow
+type Policy struct {
+	MinLength     int  // In characters
+	MaxLength     int  // In bytes
+	MinScore      int  // 0-4, see Score
+	CheckBreached bool // Reject passwords found in the breached list
+}
+
+// DefaultPolicy is used for anything not set in the environment. MaxLength
+// stops very long inputs from being used to make hashing expensive.
+var DefaultPolicy = Policy{
+	MinLength:     8,
+	MaxLength:     128,
+	MinScore:      2,
+	CheckBreached: true,
+}
+
+// PolicyFromEnv reads PASSWORD_MIN_LENGTH, PASSWORD_MIN_SCORE and
+// PASSWORD_CHECK_BREACHED, falling back to DefaultPolicy
+func PolicyFromEnv() Policy {
+	p := DefaultPolicy
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		p.MinLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_SCORE")); err == nil && v >= 0 && v <= 4 {
+		p.MinScore = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("PASSWORD_CHECK_BREACHED")); err == nil {
+		p.CheckBreached = v
+	}
+	return p
+}
+
+// Validate checks a password against every rule and returns all the
+// violations, or nil if it's acceptable. userInputs are the username,
+// email and similar values the password must not contain.
+func (p Policy) Validate(password string, userInputs ...string) []Violation {
+	var violations []Violation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{ViolationTooShort, fmt.Sprintf("Password must be at least %d characters", p.MinLength)})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, Violation{ViolationTooLong, fmt.Sprintf("Password must be at most %d bytes", p.MaxLength)})
+	}
+
+	if containsPersonalInfo(password, userInputs) {
+		violations = append(violations, Violation{ViolationPersonalInfo, "Password must not contain your username or email"})
+	}
+
+	if Score(password, userInputs...) < p.MinScore {
+		violations = append(violations, Violation{ViolationTooWeak, "Password is too easy to guess. Try a longer phrase of unrelated words."})
+	}
+
+	if p.CheckBreached {
+		list, err := DefaultBreachedList()
+		if err != nil {
+			log.Printf("Breached password list unavailable: %v", err)
+		} else if list.Contains(password) {
+			violations = append(violations, Violation{ViolationBreached, "This password has appeared in a data breach and can't be used"})
+		}
+	}
+
+	return violations
+}
+
+// containsPersonalInfo reports whether the password contains the username
+// or the local part of the email, ignoring case
+func containsPersonalInfo(password string, userInputs []string) bool {
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if local, _, found := strings.Cut(input, "@"); found {
+			input = local
+		}
+		if utf8.RuneCountInString(input) >= 3 && strings.Contains(lower, input) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Score thresholds in estimated guesses, as used by zxcvbn
+var scoreThresholds = []float64{1e3, 1e6, 1e8, 1e10}
+
+//go:embed common.txt
+var commonList string
+
+var (
+	dictionaryOnce sync.Once
+	dictionary     map[string]int // word -> rank, 1 is the most common
+)
+
+func commonWords() map[string]int {
+	dictionaryOnce.Do(func() {
+		dictionary = map[string]int{}
+		for i, word := range strings.Fields(commonList) {
+			if _, ok := dictionary[word]; !ok {
+				dictionary[word] = i + 1
+			}
+		}
+	})
+	return dictionary
+}
+
+var l33t = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "qazwsxedc"}
+
+// Score rates a password from 0 (trivially guessable) to 4 (very hard to
+// guess), in the spirit of zxcvbn. userInputs such as the username and
+// email are treated as the most likely words of all.
+func Score(password string, userInputs ...string) int {
+	guesses := EstimateGuesses(password, userInputs...)
+	for score, threshold := range scoreThresholds {
+		if guesses < threshold {
+			return score
+		}
+	}
+	return len(scoreThresholds)
+}
+
+// EstimateGuesses estimates how many guesses an attacker needs by splitting
+// the password into the cheapest sequence of known patterns: dictionary
+// words, repeats, sequences, keyboard runs, years and brute-forced characters.
+func EstimateGuesses(password string, userInputs ...string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 1
+	}
+
+	user := map[string]bool{}
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(part)) >= 3 {
+				user[part] = true
+			}
+		}
+	}
+
+	// best[j] is the fewest guesses for the first j characters
+	best := make([]float64, n+1)
+	best[0] = 1
+	for j := 1; j <= n; j++ {
+		best[j] = math.Inf(1)
+		for i := 0; i < j; i++ {
+			if g := best[i] * segmentGuesses(runes[i:j], user); g < best[j] {
+				best[j] = g
+			}
+		}
+	}
+	return best[n]
+}
+
+// segmentGuesses returns the guesses for the cheapest pattern matching the
+// whole segment
+func segmentGuesses(segment []rune, user map[string]bool) float64 {
+	n := len(segment)
+	// Brute force, 10 guesses per character as in zxcvbn
+	guesses := math.Pow(10, float64(n))
+	if n < 3 {
+		return guesses
+	}
+
+	word := string(segment)
+	lower := strings.ToLower(word)
+	upperFactor := 1.0
+	if lower != word {
+		upperFactor = 2
+		if lower == strings.ToLower(word[:1])+word[1:] || strings.ToUpper(word) == word {
+			upperFactor = 1.5 // Only the first letter, or every letter, capitalised
+		}
+	}
+
+	candidates := []float64{guesses}
+
+	if user[lower] {
+		candidates = append(candidates, upperFactor)
+	}
+	if rank, ok := commonWords()[lower]; ok {
+		candidates = append(candidates, float64(rank)*upperFactor)
+	}
+	if unleet := l33t.Replace(lower); unleet != lower {
+		if user[unleet] {
+			candidates = append(candidates, 2*upperFactor)
+		}
+		if rank, ok := commonWords()[unleet]; ok {
+			candidates = append(candidates, float64(rank)*2*upperFactor)
+		}
+	}
+
+	if isRepeat(segment) {
+		candidates = append(candidates, 10*float64(n))
+	}
+	if isSequence(segment) {
+		base := 26.0
+		if unicode.IsDigit(segment[0]) {
+			base = 10
+		}
+		if strings.ContainsRune("aA0z9Z1", segment[0]) {
+			base = 4
+		}
+		candidates = append(candidates, base*float64(n))
+	}
+	if n >= 4 && isKeyboardRun(lower) {
+		candidates = append(candidates, 40*float64(n))
+	}
+	if n == 4 && (strings.HasPrefix(word, "19") || strings.HasPrefix(word, "20")) && isDigits(word) {
+		candidates = append(candidates, 200) // A recent year
+	}
+
+	for _, c := range candidates {
+		if c < guesses {
+			guesses = c
+		}
+	}
+	return math.Max(guesses, 1)
+}
+
+func isRepeat(s []rune) bool {
+	for _, r := range s[1:] {
+		if r != s[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func isSequence(s []rune) bool {
+	delta := s[1] - s[0]
+	if delta != 1 && delta != -1 {
+		return false
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i]-s[i-1] != delta {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyboardRun(s string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) || strings.Contains(reverse(row), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
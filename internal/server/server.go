@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/emilythestrangee/reddit-clone/backend/internal/database"
 	"github.com/emilythestrangee/reddit-clone/backend/internal/handlers"
+	"github.com/emilythestrangee/reddit-clone/backend/internal/jobs"
 	"github.com/emilythestrangee/reddit-clone/backend/internal/middleware"
 )
 
@@ -54,6 +56,26 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Background jobs run until the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	server.RegisterOnShutdown(stopJobs)
+	go jobs.Every(jobsCtx, "purge deleted accounts", time.Hour, func(ctx context.Context) error {
+		_, err := jobs.PurgeDeletedAccounts(ctx, database.New().GetDB(), handler.Storage)
+		return err
+	})
+	go jobs.Every(jobsCtx, "clean up data exports", time.Hour, func(ctx context.Context) error {
+		return jobs.CleanupDataExports(ctx, database.New().GetDB())
+	})
+	go jobs.Every(jobsCtx, "process pending media", 5*time.Minute, func(ctx context.Context) error {
+		return jobs.ProcessPendingMedia(ctx, database.New().GetDB(), handler.Storage)
+	})
+	go jobs.Every(jobsCtx, "fetch pending link previews", 5*time.Minute, func(ctx context.Context) error {
+		return jobs.UnfurlPendingLinks(ctx, database.New().GetDB(), handler.Storage, handler.Unfurler)
+	})
+	go jobs.Every(jobsCtx, "clean up orphaned media", time.Hour, func(ctx context.Context) error {
+		return jobs.CleanupOrphanedMedia(ctx, database.New().GetDB(), handler.Storage)
+	})
+
 	log.Printf("🚀 Server starting on port %s\n", port)
 	fmt.Println("📝 Press Ctrl+C to stop the server")
 
@@ -64,6 +86,9 @@ func NewServer() *http.Server {
 func (s *Server) RegisterRoutes() *gin.Engine {
 	r := gin.Default()
 
+	// GORM connection used by middleware that needs to look up sessions/users
+	db := database.New().GetDB()
+
 	// CORS configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -79,6 +104,9 @@ func (s *Server) RegisterRoutes() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	// Uploaded media
+	r.GET("/media/*key", s.handler.Media.ServeMedia)
+
 	// API routes
 	api := r.Group("/api")
 	{
@@ -90,42 +118,142 @@ func (s *Server) RegisterRoutes() *gin.Engine {
 		api.POST("/auth/google", s.handler.Auth.GoogleLogin)
 		api.POST("/auth/apple", s.handler.Auth.AppleLogin)
 
+		// Email verification
+		api.POST("/auth/verify-email", s.handler.Auth.VerifyEmail)
+
+		// Password reset
+		api.POST("/auth/forgot-password", s.handler.Auth.ForgotPassword)
+		api.POST("/auth/reset-password", s.handler.Auth.ResetPassword)
+
+		// Passwordless login
+		api.POST("/auth/magic-link", s.handler.Auth.RequestMagicLink)
+		api.POST("/auth/magic-link/consume", s.handler.Auth.ConsumeMagicLink)
+
+		// Second login step for accounts with 2FA
+		api.POST("/auth/mfa/verify", s.handler.Auth.VerifyMFA)
+		api.POST("/auth/mfa/sms", middleware.OptionalAuthMiddleware(db), s.handler.MFA.SendLoginSMS)
+		api.POST("/auth/mfa/passkey/begin", s.handler.Auth.BeginPasskeyMFA)
+		api.POST("/auth/mfa/passkey/finish", s.handler.Auth.FinishPasskeyMFA)
+
+		// Passkey login
+		api.POST("/auth/passkey/begin", s.handler.Auth.BeginPasskeyLogin)
+		api.POST("/auth/passkey/finish", s.handler.Auth.FinishPasskeyLogin)
+
 		// Post routes (public reads)
-		api.GET("/posts", s.handler.Post.GetPosts)
-		api.GET("/posts/:id", s.handler.Post.GetPost)
+		api.GET("/posts", middleware.OptionalAuthMiddleware(db), s.handler.Post.GetPosts)
+		api.GET("/posts/:id", middleware.OptionalAuthMiddleware(db), s.handler.Post.GetPost)
 
 		// Comment routes (public reads)
-		api.GET("/posts/:id/comments", s.handler.Comment.GetComments)
+		api.GET("/posts/:id/comments", middleware.OptionalAuthMiddleware(db), s.handler.Comment.GetComments)
 
 		// User routes (public reads)
-		api.GET("/users/:id", s.handler.User.GetUserProfile)
-		api.GET("/users/:id/followers", s.handler.User.GetFollowers)
-		api.GET("/users/:id/following", s.handler.User.GetFollowing)
+		api.GET("/communities/:name", s.handler.Community.GetCommunity)
+		api.GET("/users", s.handler.User.SearchUsers)
+		api.GET("/users/:id", middleware.OptionalAuthMiddleware(db), s.handler.User.GetUserProfile)
+		api.GET("/users/:id/votes", middleware.OptionalAuthMiddleware(db), s.handler.User.GetUserVotes)
+		api.GET("/users/:id/followers", middleware.OptionalAuthMiddleware(db), s.handler.User.GetFollowers)
+		api.GET("/users/:id/following", middleware.OptionalAuthMiddleware(db), s.handler.User.GetFollowing)
+
+		// Data export downloads, authorised by the signed link
+		api.GET("/exports/:id/download", s.handler.Account.DownloadDataExport)
 
 		// Protected routes (authentication required)
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(db))
+
+		// Optionally restrict posting until the email address is verified
+		requireVerified := middleware.RequireVerifiedEmail(db)
 		{
 			// Auth protected routes
 			protected.GET("/me", s.handler.Auth.GetMe)
+			protected.DELETE("/me", s.handler.Account.DeleteAccount)
+
+			// Personal data export
+			protected.POST("/me/export", s.handler.Account.RequestDataExport)
+			protected.GET("/me/export", s.handler.Account.GetDataExports)
+			protected.POST("/auth/resend-verification", s.handler.Auth.ResendVerification)
+
+			// Account settings
+			protected.PUT("/me/password", s.handler.Account.ChangePassword)
+			protected.PUT("/me/email", s.handler.Account.ChangeEmail)
+			protected.PUT("/me/username", s.handler.Account.ChangeUsername)
+			protected.GET("/me/security-events", s.handler.Account.GetSecurityEvents)
+			protected.GET("/me/preferences", s.handler.Account.GetPreferences)
+			protected.PATCH("/me/preferences", s.handler.Account.UpdatePreferences)
+
+			// Linked login providers
+			protected.GET("/me/identities", s.handler.Auth.GetIdentities)
+			protected.POST("/me/identities/:provider", s.handler.Auth.LinkIdentity)
+			protected.DELETE("/me/identities/:provider", s.handler.Auth.UnlinkIdentity)
+
+			// Passkeys
+			protected.GET("/me/passkeys", s.handler.Auth.GetPasskeys)
+			protected.POST("/me/passkeys/register/begin", s.handler.Auth.BeginPasskeyRegistration)
+			protected.POST("/me/passkeys/register/finish", s.handler.Auth.FinishPasskeyRegistration)
+			protected.PATCH("/me/passkeys/:id", s.handler.Auth.RenamePasskey)
+			protected.DELETE("/me/passkeys/:id", s.handler.Auth.DeletePasskey)
+
+			// Two-factor authentication
+			protected.GET("/me/2fa", s.handler.MFA.GetMFAStatus)
+			protected.POST("/me/2fa/totp", s.handler.MFA.SetupTOTP)
+			protected.POST("/me/2fa/totp/confirm", s.handler.MFA.ConfirmTOTP)
+			protected.DELETE("/me/2fa/totp", s.handler.MFA.DisableTOTP)
+			protected.POST("/me/2fa/recovery-codes", s.handler.MFA.RegenerateRecoveryCodes)
+			protected.POST("/me/2fa/sms", s.handler.MFA.EnableSMSMFA)
+			protected.DELETE("/me/2fa/sms", s.handler.MFA.DisableSMSMFA)
+
+			// Phone number
+			protected.POST("/me/phone", s.handler.MFA.SetPhone)
+			protected.POST("/me/phone/verify", s.handler.MFA.VerifyPhone)
+			protected.DELETE("/me/phone", s.handler.MFA.RemovePhone)
+
+			// Media uploads for posts and avatars
+			protected.POST("/media", s.handler.Media.UploadMedia)
 
 			// Post protected routes
-			protected.POST("/posts", s.handler.Post.CreatePost)
+			protected.POST("/posts", requireVerified, s.handler.Post.CreatePost)
 			protected.PUT("/posts/:id", s.handler.Post.UpdatePost)
 			protected.DELETE("/posts/:id", s.handler.Post.DeletePost)
+			protected.GET("/posts/:id/revisions", s.handler.Post.GetPostRevisions)
 			protected.POST("/posts/:id/vote", s.handler.Post.VotePost)
+			protected.PATCH("/posts/:id/flags", s.handler.Post.SetPostFlags)
+			protected.POST("/posts/:id/hide", s.handler.Post.HidePost)
+			protected.DELETE("/posts/:id/hide", s.handler.Post.UnhidePost)
+			protected.GET("/me/history", s.handler.Post.GetHistory)
+			protected.DELETE("/me/history", s.handler.Post.ClearHistory)
 
 			// Comment protected routes
-			protected.POST("/posts/:id/comments", s.handler.Comment.CreateComment)
+			protected.POST("/posts/:id/comments", requireVerified, s.handler.Comment.CreateComment)
 			protected.POST("/comments/:commentId/upvote", s.handler.Comment.UpvoteComment)
 			protected.POST("/comments/:commentId/downvote", s.handler.Comment.DownvoteComment)
 			protected.PUT("/comments/:commentId", s.handler.Comment.UpdateComment)
 			protected.DELETE("/comments/:commentId", s.handler.Comment.DeleteComment)
+			protected.GET("/comments/:commentId/revisions", s.handler.Comment.GetCommentRevisions)
+
+			// Saved posts and comments, optionally sorted into collections
+			protected.POST("/posts/:id/save", s.handler.Saved.SavePost)
+			protected.DELETE("/posts/:id/save", s.handler.Saved.UnsavePost)
+			protected.POST("/comments/:commentId/save", s.handler.Saved.SaveComment)
+			protected.DELETE("/comments/:commentId/save", s.handler.Saved.UnsaveComment)
+			protected.GET("/me/saved", s.handler.Saved.GetSaved)
+			protected.GET("/me/collections", s.handler.Saved.GetCollections)
+			protected.POST("/me/collections", s.handler.Saved.CreateCollection)
+			protected.PATCH("/me/collections/:id", s.handler.Saved.RenameCollection)
+			protected.DELETE("/me/collections/:id", s.handler.Saved.DeleteCollection)
+
+			// Community routes, moderators only
+			protected.POST("/communities", s.handler.Community.CreateCommunity)
+			protected.PATCH("/communities/:name", s.handler.Community.UpdateCommunity)
 
 			// User protected routes
 			protected.PUT("/users/:id", s.handler.User.UpdateUserProfile)
 			protected.POST("/users/:id/follow", s.handler.User.FollowUser)
 			protected.DELETE("/users/:id/follow", s.handler.User.UnfollowUser)
+
+			// Blocked users
+			protected.GET("/me/blocks", s.handler.User.GetBlocks)
+			protected.POST("/me/blocks", s.handler.User.BlockUser)
+			protected.DELETE("/me/blocks/:id", s.handler.User.UnblockUser)
 		}
 	}
 
@@ -0,0 +1,52 @@
+package sms
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Message is a text message captured by MemorySender
+type Message struct {
+	To   string
+	Body string
+}
+
+// MemorySender keeps sent messages in memory and logs them instead of
+// delivering them. Used for local development and tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(ctx context.Context, to, body string) error {
+	log.Printf("📱 SMS to %s: %s", to, body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, Message{To: to, Body: body})
+	return nil
+}
+
+// Messages returns every message sent so far
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Last returns the most recent message sent to a number
+func (s *MemorySender) Last(to string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].To == to {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
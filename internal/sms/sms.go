@@ -0,0 +1,61 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// SMSSender delivers text messages to phone numbers in E.164 format
+type SMSSender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+var (
+	ErrInvalidPhone = errors.New("phone number must be in international format, e.g. +14155550123")
+
+	e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// NormalizePhone strips common formatting characters and validates the
+// result as an E.164 number
+func NormalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		default:
+			return r
+		}
+	}, strings.TrimSpace(phone))
+
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+
+	if !e164Pattern.MatchString(normalized) {
+		return "", ErrInvalidPhone
+	}
+	return normalized, nil
+}
+
+// NewFromEnv builds a sender from the SMS_DRIVER environment variable.
+// "twilio" sends real messages; anything else keeps messages in memory and
+// logs them, which is what development and tests use.
+func NewFromEnv() SMSSender {
+	switch os.Getenv("SMS_DRIVER") {
+	case "twilio":
+		return NewTwilioSender(
+			os.Getenv("TWILIO_ACCOUNT_SID"),
+			os.Getenv("TWILIO_AUTH_TOKEN"),
+			os.Getenv("TWILIO_FROM_NUMBER"),
+			os.Getenv("TWILIO_MESSAGING_SERVICE_SID"),
+		)
+	default:
+		log.Println("📱 Using in-memory SMS sender, text messages will not be delivered")
+		return NewMemorySender()
+	}
+}
@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	valid := map[string]string{
+		"+14155550123":       "+14155550123",
+		"+1 (415) 555-0123":  "+14155550123",
+		"0044 20 7946 0958":  "+442079460958",
+		" +33.1.23.45.67.89": "+33123456789",
+	}
+	for in, want := range valid {
+		got, err := NormalizePhone(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "4155550123", "+0123456789", "+1415abc0123", "+1234"} {
+		if _, err := NormalizePhone(in); err != ErrInvalidPhone {
+			t.Errorf("NormalizePhone(%q) expected ErrInvalidPhone, got %v", in, err)
+		}
+	}
+}
+
+func TestMemorySender(t *testing.T) {
+	s := NewMemorySender()
+	ctx := context.Background()
+
+	_ = s.Send(ctx, "+14155550123", "first")
+	_ = s.Send(ctx, "+442079460958", "other")
+	_ = s.Send(ctx, "+14155550123", "second")
+
+	if got := len(s.Messages()); got != 3 {
+		t.Fatalf("expected 3 messages, got %d", got)
+	}
+
+	msg, ok := s.Last("+14155550123")
+	if !ok || msg.Body != "second" {
+		t.Fatalf("Last() = %+v, %v; want body \"second\"", msg, ok)
+	}
+
+	if _, ok := s.Last("+15550000000"); ok {
+		t.Fatal("Last() found a message for an unknown number")
+	}
+}
@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// TwilioSender sends text messages through the Twilio Messages API
+type TwilioSender struct {
+	client              *twilio.RestClient
+	from                string
+	messagingServiceSID string
+}
+
+// NewTwilioSender creates a sender. Either from (a Twilio phone number) or
+// messagingServiceSID must be set.
+func NewTwilioSender(accountSID, authToken, from, messagingServiceSID string) *TwilioSender {
+	return &TwilioSender{
+		client: twilio.NewRestClientWithParams(twilio.ClientParams{
+			Username: accountSID,
+			Password: authToken,
+		}),
+		from:                from,
+		messagingServiceSID: messagingServiceSID,
+	}
+}
+
+func (s *TwilioSender) Send(ctx context.Context, to, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(to)
+	params.SetBody(body)
+	if s.messagingServiceSID != "" {
+		params.SetMessagingServiceSid(s.messagingServiceSID)
+	} else {
+		params.SetFrom(s.from)
+	}
+
+	if _, err := s.client.Api.CreateMessage(params); err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Package storage stores uploaded files on local disk or in an
+// S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when no object exists for a key
+var ErrNotFound = errors.New("object not found")
+
+// Object is a stored file opened for reading
+type Object struct {
+	io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+// Storage keeps files under slash-separated keys such as "12/abc.jpg"
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv picks a backend from STORAGE_DRIVER: "s3" or "local" (default)
+func NewFromEnv() (Storage, error) {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Insecure:  os.Getenv("S3_INSECURE") == "true",
+		})
+	default:
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "./tmp/media"
+		}
+		log.Printf("📁 Storing uploads on local disk in %s", dir)
+		return NewLocal(dir)
+	}
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := "tests/" + time.Now().Format("20060102150405.000000000") + ".txt"
+	body := "hello, storage"
+
+	if err := s.Put(ctx, key, strings.NewReader(body), int64(len(body)), "text/plain"); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+
+	obj, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if string(data) != body || obj.Size != int64(len(body)) || obj.ContentType != "text/plain" {
+		t.Errorf("Open() = %q, size %d, type %q", data, obj.Size, obj.ContentType)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after Delete() = %v, expected ErrNotFound", err)
+	}
+
+	for _, bad := range []string{"", "/etc/passwd", "../outside", "a/../../b", "a//b"} {
+		if err := s.Put(ctx, bad, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+// TestS3 runs against a real bucket, for example a local MinIO:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	S3_TEST_ENDPOINT=localhost:9000 S3_TEST_BUCKET=test S3_TEST_ACCESS_KEY=minioadmin S3_TEST_SECRET_KEY=minioadmin go test ./internal/storage
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+
+	s, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		Bucket:    os.Getenv("S3_TEST_BUCKET"),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Insecure:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if exists, err := s.client.BucketExists(ctx, s.bucket); err != nil {
+		t.Fatal(err)
+	} else if !exists {
+		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testStorage(t, s)
+}
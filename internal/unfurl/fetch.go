@@ -0,0 +1,199 @@
+// Package unfurl fetches link previews: the OpenGraph and Twitter card
+// title, description and image of a web page. Requests only go to public
+// addresses so users can't make the server probe its own network.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Most redirects followed before giving up
+const maxRedirects = 5
+
+var (
+	// ErrBlockedAddress is returned when a URL resolves to an address the
+	// fetcher may not connect to
+	ErrBlockedAddress = errors.New("address is not allowed")
+
+	// ErrTooLarge is returned when a response is bigger than allowed
+	ErrTooLarge = errors.New("response is too large")
+
+	errNotHTML = errors.New("response is not an HTML page")
+)
+
+// Ranges that are neither private nor loopback but still aren't on the
+// public internet
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach IPv4 private ranges
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddr allows public unicast addresses on the standard web ports
+func PublicAddr(addr netip.AddrPort) bool {
+	if addr.Port() != 80 && addr.Port() != 443 {
+		return false
+	}
+	ip := addr.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetcher downloads pages and images. The zero value is not usable; create
+// one with New.
+type Fetcher struct {
+	// Allow decides which addresses may be connected to. It is checked for
+	// every connection, after DNS resolution and on every redirect, so
+	// hostnames that resolve to internal addresses are caught too.
+	Allow func(netip.AddrPort) bool
+
+	MaxPageBytes  int64
+	MaxImageBytes int64
+	UserAgent     string
+
+	client *http.Client
+}
+
+// New returns a Fetcher that only connects to PublicAddr addresses
+func New() *Fetcher {
+	f := &Fetcher{
+		Allow:         PublicAddr,
+		MaxPageBytes:  1 << 20,
+		MaxImageBytes: 10 << 20,
+		UserAgent:     "Mozilla/5.0 (compatible; RedditCloneBot/1.0; link previews)",
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !f.Allow(addr) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			Proxy:                 nil, // A proxy would make the connection checks meaningless
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// get requests a URL and returns the response if it succeeded
+func (f *Fetcher) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("unsupported URL %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// readLimited reads a body, failing if it's longer than limit
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Fetch downloads a page and extracts its preview. Relative image URLs are
+// resolved against the final URL after redirects.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	resp, err := f.get(ctx, rawURL, "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, errNotHTML
+	}
+
+	// Pages are cut off at the limit rather than rejected; the tags we want
+	// are in the head
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.MaxPageBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+	return parsePreview(body, resp.Request.URL)
+}
+
+// FetchImage downloads an image, failing if it's bigger than MaxImageBytes.
+// The caller should check the content, not trust the server's type.
+func (f *Fetcher) FetchImage(ctx context.Context, rawURL string) ([]byte, error) {
+	resp, err := f.get(ctx, rawURL, "image/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > f.MaxImageBytes {
+		return nil, ErrTooLarge
+	}
+	return readLimited(resp.Body, f.MaxImageBytes)
+}
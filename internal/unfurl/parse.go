@@ -0,0 +1,112 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Longest title and description kept, in characters
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// Preview is what a page says about itself
+type Preview struct {
+	Title       string
+	Description string
+	SiteName    string
+	ImageURL    string // Absolute http or https URL, or empty
+}
+
+// parsePreview reads the page head, preferring OpenGraph tags, then
+// Twitter card tags, then the plain title and description
+func parsePreview(r io.Reader, base *url.URL) (*Preview, error) {
+	meta := map[string]string{}
+	var title string
+
+	z := html.NewTokenizer(r)
+	inTitle := false
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				break loop
+			}
+			return nil, z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := z.Token()
+			switch tag.Data {
+			case "meta":
+				var key, content string
+				for _, attr := range tag.Attr {
+					switch attr.Key {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				// The first occurrence wins, as with OpenGraph arrays
+				if _, seen := meta[key]; key != "" && content != "" && !seen {
+					meta[key] = content
+				}
+			case "title":
+				inTitle = title == ""
+			case "body":
+				break loop
+			}
+
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+
+		case html.EndTagToken:
+			switch z.Token().Data {
+			case "title":
+				inTitle = false
+			case "head":
+				break loop
+			}
+		}
+	}
+
+	first := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	preview := &Preview{
+		Title:       truncate(first(meta["og:title"], meta["twitter:title"], strings.TrimSpace(title)), maxTitleLength),
+		Description: truncate(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		SiteName:    truncate(meta["og:site_name"], maxTitleLength),
+	}
+
+	image := first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])
+	if image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			preview.ImageURL = u.String()
+		}
+	}
+	return preview, nil
+}
+
+// truncate shortens s to at most n characters, collapsing whitespace
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,169 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// allowLoopback lets a fetcher reach httptest servers
+func allowLoopback(addr netip.AddrPort) bool {
+	return addr.Addr().IsLoopback()
+}
+
+const testPage = `<!doctype html>
+<html><head>
+<title>Plain title</title>
+<meta property="og:title" content="Open &amp; Graph title">
+<meta property="og:site_name" content="Example">
+<meta name="twitter:description" content="Twitter description">
+<meta name="description" content="Plain description">
+<meta property="og:image" content="/images/cover.png">
+</head><body><meta property="og:title" content="Ignored"></body></html>`
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := New()
+	f.Allow = allowLoopback
+
+	preview, err := f.Fetch(context.Background(), server.URL+"/short")
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	want := Preview{
+		Title:       "Open & Graph title",
+		Description: "Twitter description",
+		SiteName:    "Example",
+		ImageURL:    server.URL + "/images/cover.png",
+	}
+	if *preview != want {
+		t.Errorf("Fetch() = %+v, want %+v", *preview, want)
+	}
+}
+
+func TestFetchFallsBackToTitle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>\n  Just a   title </title><meta name=description content=Words></head></html>"))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.Allow = allowLoopback
+
+	preview, err := f.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if preview.Title != "Just a title" || preview.Description != "Words" || preview.ImageURL != "" {
+		t.Errorf("Fetch() = %+v", *preview)
+	}
+}
+
+func TestFetchBlocksInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the server")
+	}))
+	defer server.Close()
+
+	// The default fetcher refuses loopback addresses
+	if _, err := New().Fetch(context.Background(), server.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch() error = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestFetchChecksRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect reached the internal server")
+	}))
+	defer internal.Close()
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, internal.URL, http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+		}
+	}))
+	defer public.Close()
+
+	// Only the "public" server's port is allowed
+	allowed := netip.MustParseAddrPort(strings.TrimPrefix(public.URL, "http://"))
+	f := New()
+	f.Allow = func(addr netip.AddrPort) bool { return addr == allowed }
+
+	if _, err := f.Fetch(context.Background(), public.URL+"/internal"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("redirect to internal address: error = %v, want ErrBlockedAddress", err)
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/file"); err == nil || !strings.Contains(err.Error(), "unsupported scheme") {
+		t.Errorf("redirect to file URL: error = %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "too many redirects") {
+		t.Errorf("redirect loop: error = %v", err)
+	}
+}
+
+func TestFetchImageLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 2048))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.Allow = allowLoopback
+	f.MaxImageBytes = 1024
+
+	if _, err := f.FetchImage(context.Background(), server.URL); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("FetchImage() error = %v, want ErrTooLarge", err)
+	}
+
+	f.MaxImageBytes = 4096
+	data, err := f.FetchImage(context.Background(), server.URL)
+	if err != nil || len(data) != 2048 {
+		t.Errorf("FetchImage() = %d bytes, %v", len(data), err)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14:443":      true,
+		"93.184.215.14:80":       true,
+		"93.184.215.14:22":       false,
+		"127.0.0.1:80":           false,
+		"10.1.2.3:80":            false,
+		"172.16.0.1:80":          false,
+		"192.168.1.1:443":        false,
+		"169.254.169.254:80":     false, // Cloud metadata service
+		"100.64.0.1:80":          false,
+		"0.0.0.0:80":             false,
+		"[::1]:443":              false,
+		"[::ffff:127.0.0.1]:80":  false,
+		"[fd00::1]:443":          false,
+		"[fe80::1]:443":          false,
+		"[64:ff9b::a00:1]:443":   false,
+		"[2606:2800:220:1::]:80": true,
+	}
+	for addr, want := range tests {
+		if got := PublicAddr(netip.MustParseAddrPort(addr)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
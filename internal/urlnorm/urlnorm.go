@@ -0,0 +1,117 @@
+// Package urlnorm normalises URLs submitted in link posts so the same page
+// always ends up with the same URL and domain.
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidURL is returned for URLs that aren't absolute http(s) URLs
+var ErrInvalidURL = errors.New("URL must be an absolute http or https URL")
+
+// Query parameters that only track where a click came from
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "mc_cid": true, "mc_eid": true,
+	"igshid": true, "yclid": true, "_hsenc": true, "_hsmi": true, "ref_src": true,
+}
+
+// Normalize returns the canonical form of a URL and its display domain.
+//
+// The scheme and host are lower-cased, internationalised hosts are converted
+// to punycode, default ports, fragments and tracking parameters are removed,
+// the remaining query parameters are sorted and an empty path becomes "/".
+// The domain is the host without a leading "www.".
+func Normalize(raw string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > 2048 {
+		return "", "", ErrInvalidURL
+	}
+	// Accept "example.com/page" as shorthand for https
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", ErrInvalidURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", ErrInvalidURL
+	}
+	if u.User != nil || u.Opaque != "" {
+		return "", "", ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", "", ErrInvalidURL
+	}
+	if net.ParseIP(host) == nil {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil || !strings.Contains(ascii, ".") {
+			return "", "", ErrInvalidURL
+		}
+		host = ascii
+	}
+
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = encodeSorted(query)
+
+	return u.String(), strings.TrimPrefix(host, "www."), nil
+}
+
+// encodeSorted encodes query parameters sorted by key, keeping the order of
+// repeated values
+func encodeSorted(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			if value != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(value))
+			}
+		}
+	}
+	return b.String()
+}